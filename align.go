@@ -0,0 +1,123 @@
+package jsondiff
+
+import "fmt"
+
+// Within an Array delta, Deleted and Moved.OldPosition refer to indices in
+// the left array, while Added, Moved.NewPosition, Modified, Object and Array
+// refer to indices in the right array. A Modified, Object or Array delta may
+// share its position with a Moved delta, describing changes to the moved
+// element.
+
+type arrayItemKind int
+
+const (
+	itemKept      arrayItemKind = iota // present on both sides in the same order
+	itemDeleted                        // present only in the left array
+	itemAdded                          // present only in the right array
+	itemMovedFrom                      // old position of a moved element
+	itemMovedTo                        // new position of a moved element
+)
+
+// arrayItem is a single step of an aligned walk over both sides of an array.
+type arrayItem struct {
+	kind   arrayItemKind
+	left   int   // index in the left array, -1 for added items
+	right  int   // index in the right array, -1 for deleted items
+	delta  Delta // Added, Deleted or Moved delta responsible for the item
+	change Delta // Modified, Object or Array delta at the right index
+}
+
+// alignArray orders array deltas into a walk over both arrays: items that
+// exist only on the left precede items that exist only on the right, which
+// precede the next element kept on both sides. Pass leftLen < 0 if the length
+// of the left array is unknown; the minimal length consistent with deltas is
+// assumed then.
+func alignArray(leftLen int, deltas []Delta) (items []arrayItem, rightLen int, err error) {
+	gone := make(map[int]Delta)    // left indices
+	arrived := make(map[int]Delta) // right indices
+	changes := make(map[int]Delta) // right indices
+	var maxLeft, maxRight int
+
+	claim := func(m map[int]Delta, pos Position, delta Delta) (int, error) {
+		index, ok := pos.(Index)
+		if !ok || index < 0 {
+			return 0, fmt.Errorf("invalid array position %v in %T", pos, delta)
+		}
+		if _, dup := m[int(index)]; dup {
+			return 0, fmt.Errorf("conflicting deltas at array index %d", index)
+		}
+		m[int(index)] = delta
+		return int(index), nil
+	}
+
+	for _, delta := range deltas {
+		var l, r = -1, -1
+		switch d := delta.(type) {
+		case *Deleted:
+			l, err = claim(gone, d.Position, d)
+		case *Added:
+			r, err = claim(arrived, d.Position, d)
+		case *Moved:
+			l, err = claim(gone, d.OldPosition, d)
+			if err == nil {
+				r, err = claim(arrived, d.NewPosition, d)
+			}
+		case *Modified:
+			r, err = claim(changes, d.Position, d)
+		case *Object:
+			r, err = claim(changes, d.Position, d)
+		case *Array:
+			r, err = claim(changes, d.Position, d)
+		default:
+			err = fmt.Errorf("unknown delta type %T", delta)
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		maxLeft = max(maxLeft, l+1)
+		maxRight = max(maxRight, r+1)
+	}
+	for r := range changes {
+		if _, ok := arrived[r].(*Added); ok {
+			return nil, 0, fmt.Errorf("conflicting deltas at array index %d", r)
+		}
+	}
+
+	if leftLen < 0 {
+		leftLen = max(maxLeft, maxRight-len(arrived)+len(gone))
+	} else if maxLeft > leftLen {
+		return nil, 0, fmt.Errorf("array index %d out of range [0:%d]", maxLeft-1, leftLen)
+	}
+	rightLen = leftLen - len(gone) + len(arrived)
+	if maxRight > rightLen {
+		return nil, 0, fmt.Errorf("array index %d out of range [0:%d]", maxRight-1, rightLen)
+	}
+
+	items = make([]arrayItem, 0, leftLen+len(arrived))
+	l, r := 0, 0
+	for {
+		for ; l < leftLen && gone[l] != nil; l++ {
+			switch d := gone[l].(type) {
+			case *Deleted:
+				items = append(items, arrayItem{kind: itemDeleted, left: l, right: -1, delta: d})
+			case *Moved:
+				items = append(items, arrayItem{kind: itemMovedFrom, left: l, right: int(d.NewPosition.(Index)), delta: d, change: changes[int(d.NewPosition.(Index))]})
+			}
+		}
+		for ; r < rightLen && arrived[r] != nil; r++ {
+			switch d := arrived[r].(type) {
+			case *Added:
+				items = append(items, arrayItem{kind: itemAdded, left: -1, right: r, delta: d})
+			case *Moved:
+				items = append(items, arrayItem{kind: itemMovedTo, left: int(d.OldPosition.(Index)), right: r, delta: d, change: changes[r]})
+			}
+		}
+		if l >= leftLen || r >= rightLen {
+			break
+		}
+		items = append(items, arrayItem{kind: itemKept, left: l, right: r, change: changes[r]})
+		l++
+		r++
+	}
+	return items, rightLen, nil
+}
@@ -0,0 +1,236 @@
+package jsondiff
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// MismatchError reports a delta that does not match the document it is
+// applied to or formatted against.
+type MismatchError struct {
+	Path   string // JSON Pointer of the offending value
+	Delta  Delta  // nil if the deltas of an array are inconsistent as a whole
+	Reason string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("jsondiff: %s: %s", pathOrRoot(e.Path), e.Reason)
+}
+
+// Apply applies the diff to the left document, reproducing the right one;
+// arrays compared with UnorderedArrays are reproduced up to element order.
+// Containers along the changed paths are copied; unchanged subtrees are
+// shared with left. A *MismatchError is returned if left does not hold the
+// values the diff expects.
+func (diff Diff) Apply(left any) (any, error) {
+	return applier{}.diff(left, diff)
+}
+
+// Revert undoes the diff on the right document, reproducing the left one.
+// It is the inverse of Apply and fails the same way.
+func (diff Diff) Revert(right any) (any, error) {
+	return applier{reverse: true}.diff(right, diff)
+}
+
+type applier struct {
+	reverse bool
+}
+
+func (a applier) diff(doc any, diff Diff) (any, error) {
+	if d, ok := rootModified(diff); ok {
+		return a.value("", doc, d)
+	}
+	switch v := doc.(type) {
+	case map[string]any:
+		return a.object("", v, diff)
+	case []any:
+		return a.array("", v, diff)
+	default:
+		if len(diff) > 0 {
+			return nil, &MismatchError{"", diff[0], fmt.Sprintf("expected an object or an array, got %T", doc)}
+		}
+		return doc, nil
+	}
+}
+
+// value returns v with a Modified, Object or Array delta applied.
+func (a applier) value(path string, v any, delta Delta) (any, error) {
+	switch d := delta.(type) {
+	case *Modified:
+		oldValue, newValue := d.OldValue, d.NewValue
+		if a.reverse {
+			oldValue, newValue = newValue, oldValue
+		}
+		if !reflect.DeepEqual(v, oldValue) {
+			return nil, &MismatchError{path, delta, fmt.Sprintf("expected %v, got %v", oldValue, v)}
+		}
+		return newValue, nil
+	case *Object:
+		object, ok := v.(map[string]any)
+		if !ok {
+			return nil, &MismatchError{path, delta, fmt.Sprintf("expected an object, got %T", v)}
+		}
+		return a.object(path, object, d.Deltas)
+	case *Array:
+		array, ok := v.([]any)
+		if !ok {
+			return nil, &MismatchError{path, delta, fmt.Sprintf("expected an array, got %T", v)}
+		}
+		return a.array(path, array, d.Deltas)
+	default:
+		return nil, &MismatchError{path, delta, fmt.Sprintf("unexpected %T", delta)}
+	}
+}
+
+func (a applier) object(path string, object map[string]any, deltas []Delta) (map[string]any, error) {
+	result := make(map[string]any, len(object))
+	for k, v := range object {
+		result[k] = v
+	}
+
+	for _, delta := range deltas {
+		name := positionOf(delta).String()
+		childPath := path + "/" + escapePointer(name)
+
+		switch d := delta.(type) {
+		case *Added:
+			if err := a.member(result, childPath, name, d.Value, !a.reverse, d); err != nil {
+				return nil, err
+			}
+
+		case *Deleted:
+			if err := a.member(result, childPath, name, d.Value, a.reverse, d); err != nil {
+				return nil, err
+			}
+
+		case *Modified, *Object, *Array:
+			v, exists := result[name]
+			if !exists {
+				return nil, &MismatchError{childPath, delta, "no such property"}
+			}
+			v, err := a.value(childPath, v, d)
+			if err != nil {
+				return nil, err
+			}
+			result[name] = v
+
+		default:
+			return nil, &MismatchError{childPath, delta, fmt.Sprintf("unexpected %T", delta)}
+		}
+	}
+	return result, nil
+}
+
+// member adds or deletes an object member.
+func (a applier) member(object map[string]any, path, name string, value any, adding bool, delta Delta) error {
+	v, exists := object[name]
+	if adding {
+		if exists {
+			return &MismatchError{path, delta, "cannot add, already exists"}
+		}
+		object[name] = value
+	} else {
+		if !exists || !reflect.DeepEqual(v, value) {
+			return &MismatchError{path, delta, fmt.Sprintf("cannot delete, expected %v, got %v", value, v)}
+		}
+		delete(object, name)
+	}
+	return nil
+}
+
+func (a applier) array(path string, array []any, deltas []Delta) ([]any, error) {
+	if a.reverse {
+		var err error
+		deltas, err = invertArrayDeltas(array, deltas)
+		if err != nil {
+			return nil, &MismatchError{path, nil, err.Error()}
+		}
+	}
+	items, rightLen, err := alignArray(len(array), deltas)
+	if err != nil {
+		return nil, &MismatchError{path, nil, err.Error()}
+	}
+
+	result := make([]any, rightLen)
+	for _, item := range items {
+		switch item.kind {
+		case itemKept, itemMovedTo:
+			result[item.right] = array[item.left]
+		case itemAdded:
+			result[item.right] = item.delta.(*Added).Value
+		case itemDeleted:
+			if d := item.delta.(*Deleted); !reflect.DeepEqual(array[item.left], d.Value) {
+				return nil, &MismatchError{path + "/" + strconv.Itoa(item.left), d, fmt.Sprintf("cannot delete, expected %v, got %v", d.Value, array[item.left])}
+			}
+		case itemMovedFrom:
+			if d := item.delta.(*Moved); !reflect.DeepEqual(array[item.left], d.Value) {
+				return nil, &MismatchError{path + "/" + strconv.Itoa(item.left), d, fmt.Sprintf("cannot move, expected %v, got %v", d.Value, array[item.left])}
+			}
+		}
+	}
+
+	for _, item := range items {
+		if item.change != nil && item.kind != itemMovedFrom {
+			result[item.right], err = a.value(path+"/"+strconv.Itoa(item.right), result[item.right], item.change)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// invertArrayDeltas swaps the sides of array deltas, given the right array.
+// Nested deltas are left as is and get inverted when applied.
+func invertArrayDeltas(right []any, deltas []Delta) ([]Delta, error) {
+	leftLen := len(right)
+	for _, delta := range deltas {
+		switch delta.(type) {
+		case *Added:
+			leftLen--
+		case *Deleted:
+			leftLen++
+		}
+	}
+	if leftLen < 0 {
+		return nil, fmt.Errorf("expected more than %d elements", len(right))
+	}
+	items, rightLen, err := alignArray(leftLen, deltas)
+	if err != nil {
+		return nil, err
+	}
+	if rightLen != len(right) {
+		return nil, fmt.Errorf("expected %d elements, got %d", rightLen, len(right))
+	}
+
+	inverted := make([]Delta, 0, len(deltas))
+	for _, item := range items {
+		switch item.kind {
+		case itemDeleted:
+			inverted = append(inverted, &Added{Index(item.left), item.delta.(*Deleted).Value})
+		case itemAdded:
+			inverted = append(inverted, &Deleted{Index(item.right), item.delta.(*Added).Value})
+		case itemMovedTo:
+			inverted = append(inverted, &Moved{OldPosition: Index(item.right), NewPosition: Index(item.left), Value: right[item.right]})
+		}
+		if item.change != nil && item.kind != itemMovedFrom {
+			switch d := item.change.(type) {
+			case *Modified:
+				inverted = append(inverted, &Modified{Position: Index(item.left), OldValue: d.OldValue, NewValue: d.NewValue})
+			case *Object:
+				inverted = append(inverted, &Object{Position: Index(item.left), Deltas: d.Deltas})
+			case *Array:
+				inverted = append(inverted, &Array{Position: Index(item.left), Deltas: d.Deltas})
+			}
+		}
+	}
+	return inverted, nil
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
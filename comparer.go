@@ -0,0 +1,188 @@
+package jsondiff
+
+import (
+	"fmt"
+	"io"
+)
+
+// Comparer compares JSON values with a fixed set of options. Options are
+// applied once by NewComparer, and a Comparer is safe for concurrent use.
+type Comparer struct {
+	config comparer
+}
+
+// NewComparer returns a Comparer with the given options.
+func NewComparer(opts ...CompareOption) *Comparer {
+	return &Comparer{config: *newComparer(opts)}
+}
+
+// session returns a comparer holding the state of a single comparison.
+func (cmp *Comparer) session() *comparer {
+	c := cmp.config
+	c.path = nil
+	return &c
+}
+
+// Compare compares two JSON values of any type, see the Compare function.
+func (cmp *Comparer) Compare(left, right any) Diff {
+	return cmp.session().compare(left, right)
+}
+
+// CompareObjects compares two JSON objects.
+func (cmp *Comparer) CompareObjects(left, right map[string]any) Diff {
+	return cmp.session().compareObjects(left, right)
+}
+
+// CompareJSON parses two JSON documents and compares them, see the
+// CompareJSON function.
+func (cmp *Comparer) CompareJSON(left, right []byte) (*Result, error) {
+	l, err := parseJSON("left", left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := parseJSON("right", right)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Left: l, Right: r, Diff: cmp.Compare(l, r)}, nil
+}
+
+// CompareJSONReaders is like CompareJSON, but reads the documents from the
+// given readers.
+func (cmp *Comparer) CompareJSONReaders(left, right io.Reader) (*Result, error) {
+	l, err := io.ReadAll(left)
+	if err != nil {
+		return nil, fmt.Errorf("jsondiff: reading left document: %w", err)
+	}
+	r, err := io.ReadAll(right)
+	if err != nil {
+		return nil, fmt.Errorf("jsondiff: reading right document: %w", err)
+	}
+	return cmp.CompareJSON(l, r)
+}
+
+// NoMoveDetection reports array elements that changed position as deleted
+// and added instead of producing Moved deltas.
+func NoMoveDetection() CompareOption {
+	return func(c *comparer) {
+		c.noMoves = true
+	}
+}
+
+// EqualFunc replaces the equality check for values other than objects,
+// arrays and numbers, which by default requires the same type and value.
+func EqualFunc(equal func(left, right any) bool) CompareOption {
+	return func(c *comparer) {
+		c.equalFunc = equal
+	}
+}
+
+// ComparatorFunc decides whether two values are equal. For values that
+// differ, similarity between 0 and 1 tells how alike they are, which is used
+// to pair up changed array elements; a negative similarity keeps the default
+// score.
+type ComparatorFunc func(left, right any) (equal bool, similarity float64)
+
+// CompareFuncAt compares the values at paths matching the pattern (see
+// IgnorePattern) with fn instead of the default rules, e.g. to treat
+// timestamps in different time zones as equal. Objects and arrays compared
+// by fn produce at most a single Modified delta.
+func CompareFuncAt(pattern string, fn ComparatorFunc) CompareOption {
+	return func(c *comparer) {
+		c.comparators = append(c.comparators, comparatorHook{pattern: parsePathPattern(pattern, true), fn: fn})
+	}
+}
+
+// CompareFuncIf is like CompareFuncAt, but applies to values anywhere in the
+// tree for which match returns true on both sides, e.g. strings that look
+// like UUIDs.
+func CompareFuncIf(match func(value any) bool, fn ComparatorFunc) CompareOption {
+	return func(c *comparer) {
+		c.comparators = append(c.comparators, comparatorHook{match: match, fn: fn})
+	}
+}
+
+type comparatorHook struct {
+	pattern pathPattern // nil for hooks matching values
+	match   func(any) bool
+	fn      ComparatorFunc
+}
+
+// comparator returns the first hook applying to the values at the current
+// path, or nil.
+func (c *comparer) comparator(left, right any) ComparatorFunc {
+	for _, hook := range c.comparators {
+		if hook.pattern != nil && hook.pattern.match(c.path) || hook.match != nil && hook.match(left) && hook.match(right) {
+			return hook.fn
+		}
+	}
+	return nil
+}
+
+// SimilarityThreshold reports array elements replaced by a value less
+// similar than threshold, between 0 and 1, as deleted and added instead of
+// modified.
+func SimilarityThreshold(threshold float64) CompareOption {
+	return func(c *comparer) {
+		c.similarityThreshold = threshold
+	}
+}
+
+// MaxDepth stops descending into objects and arrays nested more than depth
+// levels below the root; differing values there produce a single Modified
+// delta.
+func MaxDepth(depth int) CompareOption {
+	return func(c *comparer) {
+		c.maxDepth = depth
+	}
+}
+
+// AtPath applies additional options to the values matching the path
+// pattern (see IgnorePattern) and everything nested in them, e.g.
+// AtPath("/history", NoMoveDetection()).
+func AtPath(pattern string, opts ...CompareOption) CompareOption {
+	return func(c *comparer) {
+		c.overrides = append(c.overrides, pathOverride{parsePathPattern(pattern, true), opts})
+	}
+}
+
+type pathOverride struct {
+	pattern pathPattern
+	opts    []CompareOption
+}
+
+// override returns a comparer with the options of the overrides matching
+// the current path applied, or nil if there are none.
+func (c *comparer) override() *comparer {
+	var o *comparer
+	for _, po := range c.overrides {
+		if !po.pattern.match(c.path) {
+			continue
+		}
+		if o == nil {
+			o = c.clone()
+		}
+		for _, opt := range po.opts {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// clone copies c so that applying options to the copy doesn't affect c.
+func (c *comparer) clone() *comparer {
+	o := *c
+	o.ignoredPaths = o.ignoredPaths[:len(o.ignoredPaths):len(o.ignoredPaths)]
+	o.identities = o.identities[:len(o.identities):len(o.identities)]
+	o.unordered = o.unordered[:len(o.unordered):len(o.unordered)]
+	o.overrides = o.overrides[:len(o.overrides):len(o.overrides)]
+	o.comparators = o.comparators[:len(o.comparators):len(o.comparators)]
+	if c.ignoredKeys != nil {
+		o.ignoredKeys = make(map[string]bool, len(c.ignoredKeys))
+		for key := range c.ignoredKeys {
+			o.ignoredKeys[key] = true
+		}
+	}
+	o.path = append([]Position(nil), c.path...)
+	return &o
+}
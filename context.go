@@ -0,0 +1,75 @@
+package jsondiff
+
+import "fmt"
+
+// ContextLines shows only changed lines and up to n unchanged lines around
+// each of them, like a unified diff. Longer runs of unchanged lines collapse
+// into a single "... 42 unchanged lines ..." line.
+func ContextLines(n int) FormatOption {
+	return contextLines(max(n, 0))
+}
+
+type contextLines int
+
+func (n contextLines) applyFormat(config *asciiFormatterConfig) {
+	config.ContextLines = int(n)
+}
+
+// lineCollapser filters the line stream, holding back unchanged lines until
+// it's known whether they are close enough to a change.
+type lineCollapser struct {
+	context int
+	after   int             // unchanged lines still to show after the last change
+	pending []formattedLine // unchanged lines since then, at most context+1
+	hidden  int             // unchanged lines dropped from the front of pending
+	indent  int             // indentation of the first hidden line
+}
+
+func (c *lineCollapser) add(f *asciiFormatter, line formattedLine) {
+	if line.marker != AsciiSame {
+		c.flush(f, c.context)
+		f.emit(line)
+		c.after = c.context
+		return
+	}
+	if c.after > 0 {
+		f.emit(line)
+		c.after--
+		return
+	}
+	if len(c.pending) > c.context {
+		if c.hidden == 0 {
+			c.indent = c.pending[0].indent
+		}
+		c.hidden++
+		c.pending = append(c.pending[:0], c.pending[1:]...)
+	}
+	line.text = append([]byte(nil), line.text...)
+	line.spans = append([]textSpan(nil), line.spans...)
+	c.pending = append(c.pending, line)
+}
+
+// finish writes out lines held back at the end of the document.
+func (c *lineCollapser) finish(f *asciiFormatter) {
+	c.flush(f, 0)
+}
+
+// flush writes the held back lines, keeping the last keep lines and
+// collapsing the rest. A single line is shown rather than collapsed.
+func (c *lineCollapser) flush(f *asciiFormatter, keep int) {
+	pending := c.pending
+	if hidden := c.hidden + max(len(pending)-keep, 0); hidden > 1 {
+		indent := c.indent
+		if c.hidden == 0 {
+			indent = pending[0].indent
+		}
+		text := fmt.Sprintf("... %d unchanged lines ...", hidden)
+		f.emit(formattedLine{marker: AsciiSame, indent: indent, text: []byte(text), collapsed: true})
+		pending = pending[max(len(pending)-keep, 0):]
+	}
+	for _, line := range pending {
+		f.emit(line)
+	}
+	c.pending = c.pending[:0]
+	c.hidden = 0
+}
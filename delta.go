@@ -8,6 +8,27 @@ type Delta interface {
 	PositionMatches(pos Position) bool
 }
 
+// positionOf returns the position of the delta, which is the new position
+// for a Moved delta.
+func positionOf(delta Delta) Position {
+	switch d := delta.(type) {
+	case *Added:
+		return d.Position
+	case *Deleted:
+		return d.Position
+	case *Modified:
+		return d.Position
+	case *Moved:
+		return d.NewPosition
+	case *Object:
+		return d.Position
+	case *Array:
+		return d.Position
+	default:
+		return nil
+	}
+}
+
 type Added struct {
 	Position Position
 	Value    any
@@ -7,24 +7,74 @@ import (
 
 type Diff []Delta
 
-func CompareObjects(left, right map[string]any) Diff {
+// Compare compares two JSON values of any type. Objects and arrays at the
+// root produce the same deltas as their nested counterparts would. When the
+// roots are scalars or differ in type, the Diff consists of a single Modified
+// delta with a nil Position.
+func Compare(left, right any, opts ...CompareOption) Diff {
+	return newComparer(opts).compare(left, right)
+}
+
+func (c *comparer) compare(left, right any) Diff {
+	same, delta := c.compareValues(nil, left, right)
+	if same {
+		return make(Diff, 0)
+	}
+	switch d := delta.(type) {
+	case *Object:
+		return d.Deltas
+	case *Array:
+		return d.Deltas
+	default:
+		return Diff{delta}
+	}
+}
+
+// isArray reports whether the diff describes changes to an array root.
+func (diff Diff) isArray() bool {
+	if len(diff) == 0 {
+		return false
+	}
+	_, ok := positionOf(diff[0]).(Index)
+	return ok
+}
+
+// CompareObjects compares two JSON objects.
+func CompareObjects(left, right map[string]any, opts ...CompareOption) Diff {
+	return newComparer(opts).compareObjects(left, right)
+}
+
+// isContainer reports whether v is a JSON object or array.
+func isContainer(v any) bool {
+	switch v.(type) {
+	case map[string]any, []any:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *comparer) compareObjects(left, right map[string]any) []Delta {
 	deltas := make([]Delta, 0)
 
 	names := sortedKeys(left) // stabilize delta order
 	for _, name := range names {
+		if c.ignores(Name(name)) {
+			continue
+		}
 		if rightValue, ok := right[name]; ok {
-			same, delta := compareValues(Name(name), left[name], rightValue)
+			same, delta := c.compareValues(Name(name), left[name], rightValue)
 			if !same {
 				deltas = append(deltas, delta)
 			}
-		} else {
+		} else if !c.missingLike(left[name]) {
 			deltas = append(deltas, NewDeleted(Name(name), left[name]))
 		}
 	}
 
 	names = sortedKeys(right) // stabilize delta order
 	for _, name := range names {
-		if _, ok := left[name]; !ok {
+		if _, ok := left[name]; !ok && !c.ignores(Name(name)) && !c.missingLike(right[name]) {
 			deltas = append(deltas, NewAdded(Name(name), right[name]))
 		}
 	}
@@ -38,42 +88,56 @@ type maybe struct {
 	item     any
 }
 
-func compareArrays(left, right []any) []Delta {
+func (c *comparer) compareArrays(left, right []any) []Delta {
+	// elements excluded by IgnorePath or IgnorePattern take no part
+	leftIndices, rightIndices := c.keptIndices(left), c.keptIndices(right)
+	if identity := c.arrayIdentity(); identity != nil {
+		return c.compareArraysByIdentity(left, right, leftIndices, rightIndices, identity)
+	}
+	if c.isUnordered() {
+		return c.compareArraysUnordered(left, right, leftIndices, rightIndices)
+	}
+
 	deltas := make([]Delta, 0)
 	// LCS index pairs
-	lcsPairs := lcsIndexPairs(left, right, reflect.DeepEqual)
+	lcsPairs := lcsIndexPairs(leftIndices, rightIndices, func(i, j int) bool {
+		return c.equal(Index(j), left[i], right[j])
+	})
+	for k, pair := range lcsPairs {
+		lcsPairs[k] = lcsIndexPair{Left: leftIndices[pair.Left], Right: rightIndices[pair.Right]}
+	}
 
 	// list up items not in LCS, they are maybe deleted
 	maybeDeleted := list.New() // but maybe moved or modified
 	lcsI := 0
-	for i, leftValue := range left {
+	for _, i := range leftIndices {
 		if lcsI < len(lcsPairs) && lcsPairs[lcsI].Left == i {
 			lcsI++
 		} else {
-			maybeDeleted.PushBack(maybe{index: i, lcsIndex: lcsI, item: leftValue})
+			maybeDeleted.PushBack(maybe{index: i, lcsIndex: lcsI, item: left[i]})
 		}
 	}
 
 	// list up items not in LCS, they are maybe Added
 	maybeAdded := list.New() // but maybe moved or modified
 	lcsI = 0
-	for i, rightValue := range right {
+	for _, i := range rightIndices {
 		if lcsI < len(lcsPairs) && lcsPairs[lcsI].Right == i {
 			lcsI++
 		} else {
-			maybeAdded.PushBack(maybe{index: i, lcsIndex: lcsI, item: rightValue})
+			maybeAdded.PushBack(maybe{index: i, lcsIndex: lcsI, item: right[i]})
 		}
 	}
 
 	// find moved items
 	var delNext *list.Element // for prefetch to remove item in iteration
-	for delCandidate := maybeDeleted.Front(); delCandidate != nil; delCandidate = delNext {
+	for delCandidate := maybeDeleted.Front(); delCandidate != nil && !c.noMoves; delCandidate = delNext {
 		delCan := delCandidate.Value.(maybe)
 		delNext = delCandidate.Next()
 
 		for addCandidate := maybeAdded.Front(); addCandidate != nil; addCandidate = addCandidate.Next() {
 			addCan := addCandidate.Value.(maybe)
-			if reflect.DeepEqual(delCan.item, addCan.item) {
+			if c.equal(Index(addCan.index), delCan.item, addCan.item) {
 				deltas = append(deltas, NewMoved(Index(delCan.index), Index(addCan.index), delCan.item))
 				maybeAdded.Remove(addCandidate)
 				maybeDeleted.Remove(delCandidate)
@@ -128,7 +192,7 @@ func compareArrays(left, right []any) []Delta {
 
 		if len(delSlice) > 0 && len(addSlice) > 0 {
 			var bestDeltas []Delta
-			bestDeltas, delSlice, addSlice = maximizeSimilarities(delSlice, addSlice)
+			bestDeltas, delSlice, addSlice = c.maximizeSimilarities(delSlice, addSlice)
 			for _, delta := range bestDeltas {
 				deltas = append(deltas, delta)
 			}
@@ -145,22 +209,65 @@ func compareArrays(left, right []any) []Delta {
 	return deltas
 }
 
-func compareValues(position Position, left, right any) (same bool, delta Delta) {
+func (c *comparer) compareValues(position Position, left, right any) (same bool, delta Delta) {
+	c.push(position)
+	defer c.pop(position)
+	if o := c.override(); o != nil {
+		return o.compareHere(position, left, right)
+	}
+	return c.compareHere(position, left, right)
+}
+
+// compareHere compares the values at the current path.
+func (c *comparer) compareHere(position Position, left, right any) (same bool, delta Delta) {
+	if fn := c.comparator(left, right); fn != nil {
+		equal, similarity := fn(left, right)
+		if equal {
+			return true, nil
+		}
+		d := NewModified(position, left, right)
+		if similarity >= 0 {
+			d.similarity = min(similarity, 1)
+		}
+		return false, d
+	}
+
+	if numeric, equal := c.numbersEqual(left, right); numeric {
+		if !equal {
+			return false, NewModified(position, left, right)
+		}
+		return true, nil
+	}
+
+	if c.equalFunc != nil && !isContainer(left) && !isContainer(right) {
+		if !c.equalFunc(left, right) {
+			return false, NewModified(position, left, right)
+		}
+		return true, nil
+	}
+
 	if reflect.TypeOf(left) != reflect.TypeOf(right) {
 		return false, NewModified(position, left, right)
 	}
 
+	if c.maxDepth > 0 && len(c.path) > c.maxDepth && isContainer(left) {
+		if !c.equalHere(left, right) {
+			return false, NewModified(position, left, right)
+		}
+		return true, nil
+	}
+
 	switch left.(type) {
 	case map[string]any:
 		l := left.(map[string]any)
-		childDeltas := CompareObjects(l, right.(map[string]any))
+		childDeltas := c.compareObjects(l, right.(map[string]any))
 		if len(childDeltas) > 0 {
 			return false, NewObject(position, childDeltas)
 		}
 
 	case []any:
 		l := left.([]any)
-		childDeltas := compareArrays(l, right.([]any))
+		childDeltas := c.compareArrays(l, right.([]any))
 
 		if len(childDeltas) > 0 {
 			return false, NewArray(position, childDeltas)
@@ -175,15 +282,22 @@ func compareValues(position Position, left, right any) (same bool, delta Delta)
 	return true, nil
 }
 
-func maximizeSimilarities(left []maybe, right []maybe) (resultDeltas []Delta, freeLeft, freeRight []maybe) {
+func (c *comparer) maximizeSimilarities(left []maybe, right []maybe) (resultDeltas []Delta, freeLeft, freeRight []maybe) {
 	deltaTable := make([][]Delta, len(left))
 	for i := 0; i < len(left); i++ {
 		deltaTable[i] = make([]Delta, len(right))
 	}
+	similarities := make([][]float64, len(left))
 	for i, leftValue := range left {
+		similarities[i] = make([]float64, len(right))
 		for j, rightValue := range right {
-			_, delta := compareValues(Index(rightValue.index), leftValue.item, rightValue.item)
+			same, delta := c.compareValues(Index(rightValue.index), leftValue.item, rightValue.item)
 			deltaTable[i][j] = delta
+			if same {
+				similarities[i][j] = 1 // only without move detection
+			} else {
+				similarities[i][j] = delta.Similarity()
+			}
 		}
 	}
 
@@ -199,7 +313,7 @@ func maximizeSimilarities(left []maybe, right []maybe) (resultDeltas []Delta, fr
 		for y := sizeY - 2; y >= 0; y-- {
 			prevX := dpTable[x+1][y]
 			prevY := dpTable[x][y+1]
-			score := deltaTable[x][y].Similarity() + dpTable[x+1][y+1]
+			score := similarities[x][y] + dpTable[x+1][y+1]
 
 			dpTable[x][y] = max(prevX, prevY, score)
 		}
@@ -230,17 +344,25 @@ func maximizeSimilarities(left []maybe, right []maybe) (resultDeltas []Delta, fr
 		} else if y+1 < yValidLength && current == nextY {
 			freeRight = append(freeRight, right[y])
 			y++
+		} else if delta := deltaTable[x][y]; delta == nil {
+			x++ // equal elements stay in place
+			y++
+		} else if similarities[x][y] < c.similarityThreshold {
+			freeLeft = append(freeLeft, left[x])
+			freeRight = append(freeRight, right[y])
+			x++
+			y++
 		} else {
-			resultDeltas = append(resultDeltas, deltaTable[x][y])
+			resultDeltas = append(resultDeltas, delta)
 			x++
 			y++
 		}
 	}
 	for ; x < sizeX-1; x++ {
-		freeLeft = append(freeLeft, left[x-1])
+		freeLeft = append(freeLeft, left[x])
 	}
 	for ; y < sizeY-1; y++ {
-		freeRight = append(freeRight, right[y-1])
+		freeRight = append(freeRight, right[y])
 	}
 
 	return resultDeltas, freeLeft, freeRight
@@ -0,0 +1,417 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		left     string
+		right    string
+		opts     []CompareOption
+		expected string
+	}{
+		{
+			name:  "ignore_path",
+			left:  `{"meta": {"etag": "a", "id": 1}, "etag": "b"}`,
+			right: `{"meta": {"etag": "c", "id": 1}, "etag": "d"}`,
+			opts:  []CompareOption{IgnorePath("/meta/etag")},
+			expected: ` {
+-  "etag": "b",
++  "etag": "d",
+   "meta": {
+     "etag": "a",
+     "id": 1
+   }
+ }`,
+		},
+		{
+			name:  "ignore_pattern",
+			left:  `{"items": [{"id": 1, "updatedAt": "x"}, {"id": 2, "updatedAt": "y"}], "updatedAt": "z"}`,
+			right: `{"items": [{"id": 1, "updatedAt": "p"}, {"id": 3}], "updatedAt": "q"}`,
+			opts:  []CompareOption{IgnorePattern("/items/*/updatedAt")},
+			expected: ` {
+   "items": [
+     {
+       "id": 1,
+       "updatedAt": "x"
+     },
+     {
+-      "id": 2,
++      "id": 3,
+       "updatedAt": "y"
+     }
+   ],
+-  "updatedAt": "z"
++  "updatedAt": "q"
+ }`,
+		},
+		{
+			name:  "ignore_array_index",
+			left:  `{"items": [1, 2, 3]}`,
+			right: `{"items": [1, 5, 3]}`,
+			opts:  []CompareOption{IgnorePath("/items/1")},
+			expected: ` {
+   "items": [
+     1,
+     2,
+     3
+   ]
+ }`,
+		},
+		{
+			name:  "ignore_array_elements",
+			left:  `{"items": [1, 2, 3], "n": 1}`,
+			right: `{"items": [4], "n": 2}`,
+			opts:  []CompareOption{IgnorePattern("/items/*")},
+			expected: ` {
+   "items": [
+     1,
+     2,
+     3
+   ],
+-  "n": 1
++  "n": 2
+ }`,
+		},
+		{
+			name:  "ignore_key",
+			left:  `{"requestId": 1, "data": {"requestId": 2, "value": [{"requestId": 3}]}}`,
+			right: `{"data": {"requestId": 5, "value": [{}]}}`,
+			opts:  []CompareOption{IgnoreKey("requestId")},
+			expected: ` {
+   "data": {
+     "requestId": 2,
+     "value": [
+       {
+         "requestId": 3
+       }
+     ]
+   },
+   "requestId": 1
+ }`,
+		},
+		{
+			name:  "ignore_deep_wildcard",
+			left:  `{"a": {"b": {"etag": 1}}, "etag": 2, "c": 3}`,
+			right: `{"a": {"b": {"etag": 4}}, "c": 3}`,
+			opts:  []CompareOption{IgnorePattern("/**/etag")},
+			expected: ` {
+   "a": {
+     "b": {
+       "etag": 1
+     }
+   },
+   "c": 3,
+   "etag": 2
+ }`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, right := parseTestJSON(tt.left), parseTestJSON(tt.right)
+			actual := Compare(left, right, tt.opts...).Format(left)
+			if actual != tt.expected {
+				t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCompareNumbers(t *testing.T) {
+	tenth := 0.1 // avoid constant folding
+	tests := []struct {
+		left, right any
+		opts        []CompareOption
+		same        bool
+	}{
+		{10, 10.0, nil, true},
+		{int8(-3), json.Number("-3.0"), nil, true},
+		{uint64(math.MaxUint64), json.Number("18446744073709551615"), nil, true},
+		{uint64(math.MaxUint64), json.Number("18446744073709551614"), nil, false},
+		{json.Number("1e2"), float32(100), nil, true},
+		{10, 10.5, nil, false},
+		{10, "10", nil, false},
+		{tenth + 0.2, 0.3, nil, false},
+		{tenth + 0.2, 0.3, []CompareOption{NumericTolerance(0, 1e-9)}, true},
+		{100.0, 100.4, []CompareOption{NumericTolerance(0.5, 0)}, true},
+		{100.0, 100.6, []CompareOption{NumericTolerance(0.5, 0)}, false},
+		{[]any{1, map[string]any{"a": 2}}, []any{1.0, map[string]any{"a": json.Number("2")}}, nil, true},
+	}
+	for _, tt := range tests {
+		diff := Compare(tt.left, tt.right, tt.opts...)
+		if same := len(diff) == 0; same != tt.same {
+			t.Errorf("Compare(%#v, %#v) same = %v, expected %v", tt.left, tt.right, same, tt.same)
+		}
+	}
+}
+
+func TestMatchArrayBy(t *testing.T) {
+	left := parseTestJSON(`{"users": [{"id": 1, "name": "a"}, {"id": 2, "name": "b"}, {"id": 3, "name": "c"}, {"name": "anonymous"}]}`)
+	right := parseTestJSON(`{"users": [{"id": 3, "name": "c"}, {"id": 1, "name": "a"}, {"id": 2, "name": "B"}, {"id": 4, "name": "d"}]}`)
+	diff := Compare(left, right, MatchArrayBy("/users", "id"))
+
+	expected := `users[2{Modified(name)} Moved(2→0) Deleted(3) Added(3)]`
+	if actual := describeDiff(diff); actual != expected {
+		t.Errorf("** DELTAS:\n%s\n\nEXPECTED:\n%s", actual, expected)
+	}
+	if actual, err := diff.Apply(left); err != nil || !reflect.DeepEqual(actual, right) {
+		t.Errorf("Apply = %v, %v, expected %v", actual, err, right)
+	}
+	if actual, err := diff.Revert(right); err != nil || !reflect.DeepEqual(actual, left) {
+		t.Errorf("Revert = %v, %v, expected %v", actual, err, left)
+	}
+}
+
+func TestMatchArrayByNonScalarID(t *testing.T) {
+	left := parseTestJSON(`{"users": [{"id": {"x": 1}, "name": "a"}, {"id": [1], "name": "b"}, {"id": 2, "name": "c"}]}`)
+	right := parseTestJSON(`{"users": [{"id": 2, "name": "C"}, {"id": {"x": 1}, "name": "a"}]}`)
+	diff := Compare(left, right, MatchArrayBy("/users", "id"))
+
+	expected := `users[Deleted(0) Deleted(1) 0{Modified(name)} Added(1)]`
+	if actual := describeDiff(diff); actual != expected {
+		t.Errorf("** DELTAS:\n%s\n\nEXPECTED:\n%s", actual, expected)
+	}
+
+	identity := func(element any) (any, bool) { return element, true }
+	diff = Compare(left, right, MatchArrayByFunc("/users", identity))
+	expected = `users[Deleted(0) Deleted(1) Deleted(2) Added(0) Added(1)]`
+	if actual := describeDiff(diff); actual != expected {
+		t.Errorf("** DELTAS:\n%s\n\nEXPECTED:\n%s", actual, expected)
+	}
+}
+
+// describeDiff summarizes the structure of a diff.
+func describeDiff(diff Diff) string {
+	var buf strings.Builder
+	for i, delta := range diff {
+		if i > 0 {
+			buf.WriteString(" ")
+		}
+		switch d := delta.(type) {
+		case *Object:
+			fmt.Fprintf(&buf, "%v{%s}", d.Position, describeDiff(d.Deltas))
+		case *Array:
+			fmt.Fprintf(&buf, "%v[%s]", d.Position, describeDiff(d.Deltas))
+		case *Moved:
+			fmt.Fprintf(&buf, "Moved(%v→%v)", d.OldPosition, d.NewPosition)
+		default:
+			fmt.Fprintf(&buf, "%s(%v)", reflect.TypeOf(delta).Elem().Name(), positionOf(delta))
+		}
+	}
+	return buf.String()
+}
+
+func TestUnorderedArrays(t *testing.T) {
+	tests := []struct {
+		name     string
+		left     string
+		right    string
+		opts     []CompareOption
+		expected string
+	}{
+		{"reordered", `{"tags": ["a", "b", "c"]}`, `{"tags": ["c", "a", "b"]}`, []CompareOption{UnorderedArrays()}, ``},
+		{"multiset", `{"tags": ["a", "b", "a"]}`, `{"tags": ["b", "a", "c"]}`, []CompareOption{UnorderedArrays()}, `tags[Deleted(2) Added(2)]`},
+		{"nested", `{"x": [{"p": ["r", "w"]}, 1]}`, `{"x": [1, {"p": ["w", "r"]}]}`, []CompareOption{UnorderedArrays()}, ``},
+		{"per_path", `{"tags": ["a", "b"], "list": ["a", "b"]}`, `{"tags": ["b", "a"], "list": ["b", "a"]}`, []CompareOption{UnorderedArrays("/tags")}, `list[Moved(1→0)]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := Compare(parseTestJSON(tt.left), parseTestJSON(tt.right), tt.opts...)
+			if actual := describeDiff(diff); actual != tt.expected {
+				t.Errorf("** DELTAS:\n%s\n\nEXPECTED:\n%s", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestComparer(t *testing.T) {
+	tests := []struct {
+		name     string
+		left     string
+		right    string
+		opts     []CompareOption
+		expected string
+	}{
+		{"default", `{"a": [1, 2, 3]}`, `{"a": [3, 1, 2]}`, nil, `a[Moved(2→0)]`},
+		{"no_moves", `{"a": [1, 2, 3]}`, `{"a": [3, 1, 2]}`, []CompareOption{NoMoveDetection()}, `a[Added(0) Deleted(2)]`},
+		{"no_moves_identity", `{"a": [{"id": 1}, {"id": 2}]}`, `{"a": [{"id": 2}, {"id": 1}]}`, []CompareOption{NoMoveDetection(), MatchArrayBy("/a", "id")}, `a[Deleted(1) Added(0)]`},
+		{"equal_func", `{"a": "X", "b": 1}`, `{"a": "x", "b": 1}`, []CompareOption{EqualFunc(func(l, r any) bool {
+			ls, lok := l.(string)
+			rs, rok := r.(string)
+			return lok && rok && strings.EqualFold(ls, rs) || reflect.DeepEqual(l, r)
+		})}, ``},
+		{"similar", `{"a": ["abc"]}`, `{"a": ["xyz"]}`, nil, `a[Modified(0)]`},
+		{"threshold", `{"a": ["abc"]}`, `{"a": ["xyz"]}`, []CompareOption{SimilarityThreshold(0.9)}, `a[Deleted(0) Added(0)]`},
+		{"max_depth", `{"a": {"b": {"c": 1}}}`, `{"a": {"b": {"c": 2}}}`, []CompareOption{MaxDepth(1)}, `a{Modified(b)}`},
+		{"max_depth_2", `{"a": {"b": {"c": {"d": 1}}}}`, `{"a": {"b": {"c": {"d": 2}}}}`, []CompareOption{MaxDepth(2)}, `a{b{Modified(c)}}`},
+		{"at_path", `{"meta": {"etag": 1}, "etag": 1}`, `{"meta": {"etag": 2}, "etag": 2}`, []CompareOption{AtPath("/meta", IgnoreKey("etag"))}, `Modified(etag)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, right := parseTestJSON(tt.left), parseTestJSON(tt.right)
+			cmp := NewComparer(tt.opts...)
+			for i := 0; i < 2; i++ { // comparers are reusable
+				diff := cmp.Compare(left, right)
+				if actual := describeDiff(diff); actual != tt.expected {
+					t.Errorf("** DELTAS:\n%s\n\nEXPECTED:\n%s", actual, tt.expected)
+				}
+				if actual, err := diff.Apply(left); err != nil || len(Compare(actual, right, tt.opts...)) != 0 {
+					t.Errorf("Apply = %v, %v, expected %v", actual, err, right)
+				}
+			}
+		})
+	}
+}
+
+func TestComparators(t *testing.T) {
+	sameInstant := func(l, r any) (bool, float64) {
+		lt, lerr := time.Parse(time.RFC3339, fmt.Sprint(l))
+		rt, rerr := time.Parse(time.RFC3339, fmt.Sprint(r))
+		return lerr == nil && rerr == nil && lt.Equal(rt), -1
+	}
+	isUUID := func(v any) bool {
+		s, ok := v.(string)
+		return ok && len(s) == 36 && strings.Count(s, "-") == 4
+	}
+	foldCase := func(l, r any) (bool, float64) {
+		return strings.EqualFold(l.(string), r.(string)), 0
+	}
+	tests := []struct {
+		name     string
+		left     string
+		right    string
+		opts     []CompareOption
+		expected string
+	}{
+		{"path", `{"at": "2024-01-01T10:00:00Z", "b": "2024-01-01T10:00:00Z"}`, `{"at": "2024-01-01T12:00:00+02:00", "b": "2024-01-01T12:00:00+02:00"}`,
+			[]CompareOption{CompareFuncAt("/at", sameInstant)}, `Modified(b)`},
+		{"predicate", `{"ids": ["0b9a8c5e-1d2f-4a3b-9c8d-7e6f5a4b3c2d", "x"]}`, `{"ids": ["0B9A8C5E-1D2F-4A3B-9C8D-7E6F5A4B3C2D", "X"]}`,
+			[]CompareOption{CompareFuncIf(isUUID, foldCase)}, `ids[Modified(1)]`},
+		{"similarity", `{"ids": ["0b9a8c5e-1d2f-4a3b-9c8d-7e6f5a4b3c2d"]}`, `{"ids": ["0b9a8c5e-1d2f-4a3b-9c8d-000000000000"]}`,
+			[]CompareOption{CompareFuncIf(isUUID, foldCase), SimilarityThreshold(0.5)}, `ids[Deleted(0) Added(0)]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := Compare(parseTestJSON(tt.left), parseTestJSON(tt.right), tt.opts...)
+			if actual := describeDiff(diff); actual != tt.expected {
+				t.Errorf("** DELTAS:\n%s\n\nEXPECTED:\n%s", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMissingEquivalence(t *testing.T) {
+	left := `{"a": null, "b": [], "c": {}, "d": {"e": null}, "f": 1}`
+	right := `{"d": {}, "f": null, "g": []}`
+	tests := []struct {
+		name     string
+		opts     []CompareOption
+		expected string
+	}{
+		{"default", nil, `Deleted(a) Deleted(b) Deleted(c) d{Deleted(e)} Modified(f) Added(g)`},
+		{"null", []CompareOption{NullEqualsMissing()}, `Deleted(b) Deleted(c) Modified(f) Added(g)`},
+		{"empty", []CompareOption{EmptyEqualsMissing()}, `Deleted(a) d{Deleted(e)} Modified(f)`},
+		{"both", []CompareOption{NullEqualsMissing(), EmptyEqualsMissing()}, `Modified(f)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := Compare(parseTestJSON(left), parseTestJSON(right), tt.opts...)
+			if actual := describeDiff(diff); actual != tt.expected {
+				t.Errorf("** DELTAS:\n%s\n\nEXPECTED:\n%s", actual, tt.expected)
+			}
+		})
+	}
+}
+
+type testAudit struct {
+	CreatedBy string    `json:"createdBy"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type testLabel string
+
+type testAccount struct {
+	testAudit
+	ID       int             `json:"id,string"`
+	Name     string          `json:"name"`
+	Email    *string         `json:"email,omitempty"`
+	Tags     []testLabel     `json:"tags"`
+	Scores   map[int]float32 `json:"scores,omitempty"`
+	Avatar   []byte          `json:"avatar,omitempty"`
+	Extra    map[string]any  `json:"extra,omitempty"`
+	Secret   string          `json:"-"`
+	internal int
+	Parent   *testAccount `json:"parent,omitempty"`
+}
+
+func TestCompareValues(t *testing.T) {
+	email := "a@example.com"
+	left := testAccount{
+		testAudit: testAudit{CreatedBy: "admin", UpdatedAt: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+		ID:        7,
+		Name:      "Ann",
+		Tags:      []testLabel{"a", "b"},
+		Secret:    "x",
+	}
+	right := left
+	right.UpdatedAt = right.UpdatedAt.Add(time.Hour)
+	right.Email = &email
+	right.Tags = []testLabel{"a", "c"}
+	right.Scores = map[int]float32{1: 0.5}
+	right.Secret = "y"
+	right.internal = 1
+
+	result, err := CompareValues(left, &right)
+	if err != nil {
+		t.Fatalf("CompareValues error = %v", err)
+	}
+	expected := `tags[Modified(1)] Modified(updatedAt) Added(email) Added(scores)`
+	if actual := describeDiff(result.Diff); actual != expected {
+		t.Errorf("** DELTAS:\n%s\n\nEXPECTED:\n%s", actual, expected)
+	}
+	expectedJSON, _ := json.Marshal(&right)
+	if actual := result.Right; !reflect.DeepEqual(normalizeTestJSON(expectedJSON), normalizeTestJSON(mustMarshal(actual))) {
+		t.Errorf("Right = %s, expected %s", mustMarshal(actual), expectedJSON)
+	}
+
+	cyclic := &testAccount{}
+	cyclic.Parent = cyclic
+	cyclicMap := map[string]any{}
+	cyclicMap["self"] = cyclicMap
+	cyclicSlice := []any{nil}
+	cyclicSlice[0] = cyclicSlice
+	for _, value := range []any{cyclic, cyclicMap, cyclicSlice} {
+		if _, err := CompareValues(value, left); err == nil {
+			t.Errorf("CompareValues of a cyclic %T succeeded", value)
+		}
+	}
+
+	shared := []string{"a"}
+	if result, err := CompareValues([][]string{shared, shared}, [][]string{shared, shared[:0]}); err != nil || len(result.Diff) != 1 {
+		t.Errorf("CompareValues of shared slices = %v, %v, expected one delta", result, err)
+	}
+}
+
+func mustMarshal(v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func normalizeTestJSON(data []byte) any {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		panic(err)
+	}
+	return v
+}
@@ -0,0 +1,151 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// writeJSONString writes s as a JSON string literal. Non-ASCII characters
+// are escaped unless unescaped is set.
+func writeJSONString(buf *bytes.Buffer, s string, unescaped bool) {
+	buf.WriteByte('"')
+	writeJSONStringContent(buf, s, unescaped)
+	buf.WriteByte('"')
+}
+
+// writeJSONStringContent writes s escaped as in a JSON string literal,
+// without the quotes.
+func writeJSONStringContent(buf *bytes.Buffer, s string, unescaped bool) {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch {
+		case r == '"':
+			buf.WriteString(`\"`)
+		case r == '\\':
+			buf.WriteString(`\\`)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r == '\b':
+			buf.WriteString(`\b`)
+		case r == '\f':
+			buf.WriteString(`\f`)
+		case r < 0x20 || r == 0x7f:
+			writeUnicodeEscape(buf, r)
+		case r < utf8.RuneSelf:
+			buf.WriteByte(byte(r))
+		case r == utf8.RuneError && size == 1:
+			buf.WriteString(`\ufffd`) // invalid UTF-8
+		case unescaped && r != '\u2028' && r != '\u2029':
+			buf.WriteRune(r)
+		default:
+			writeUnicodeEscape(buf, r)
+		}
+	}
+}
+
+// writeUnicodeEscape writes r as \uXXXX, using a surrogate pair if needed.
+func writeUnicodeEscape(buf *bytes.Buffer, r rune) {
+	if r > 0xffff {
+		r -= 0x10000
+		writeUnicodeEscape(buf, 0xd800+(r>>10))
+		writeUnicodeEscape(buf, 0xdc00+(r&0x3ff))
+		return
+	}
+	buf.WriteString(`\u`)
+	for shift := 12; shift >= 0; shift -= 4 {
+		buf.WriteByte(hexDigits[(r>>shift)&0xf])
+	}
+}
+
+// writeJSONScalar writes a non-container value as a JSON token.
+func writeJSONScalar(buf *bytes.Buffer, value any, unescaped bool) {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case string:
+		writeJSONString(buf, v, unescaped)
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		if v == "" {
+			buf.WriteString("0")
+		} else {
+			buf.WriteString(string(v))
+		}
+	default:
+		rv := reflect.ValueOf(value)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			buf.WriteString(strconv.FormatInt(rv.Int(), 10))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			buf.WriteString(strconv.FormatUint(rv.Uint(), 10))
+		case reflect.Float32:
+			writeJSONFloat(buf, rv.Float(), 32)
+		case reflect.Float64:
+			writeJSONFloat(buf, rv.Float(), 64)
+		default:
+			writeJSONFallback(buf, value, unescaped)
+		}
+	}
+}
+
+// writeJSONFloat formats floats the way encoding/json does. NaN and
+// infinities have no JSON representation and are written as strings.
+func writeJSONFloat(buf *bytes.Buffer, f float64, bits int) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		buf.WriteString(`"` + strconv.FormatFloat(f, 'g', -1, bits) + `"`)
+		return
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b := strconv.AppendFloat(nil, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	buf.Write(b)
+}
+
+// writeJSONFallback writes values of other types, such as structs or typed
+// slices, using encoding/json.
+func writeJSONFallback(buf *bytes.Buffer, value any, unescaped bool) {
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		writeJSONString(buf, fmt.Sprint(value), unescaped)
+		return
+	}
+	raw := bytes.TrimRight(out.Bytes(), "\n")
+	if unescaped {
+		buf.Write(raw)
+		return
+	}
+	// non-ASCII characters only occur within strings, so can be escaped as is
+	for len(raw) > 0 {
+		r, size := utf8.DecodeRune(raw)
+		if r < utf8.RuneSelf {
+			buf.WriteByte(raw[0])
+		} else {
+			writeUnicodeEscape(buf, r)
+		}
+		raw = raw[size:]
+	}
+}
@@ -1,65 +1,277 @@
 package jsondiff
 
 import (
+	"bufio"
 	"bytes"
-	"errors"
 	"fmt"
+	"io"
+	"reflect"
 	"sort"
 	"strings"
+	"unicode/utf8"
 )
 
-// FormatOption can be passed to Diff.Format. Treat these as opaque, i.e. don't rely on the underlying type or values of FormatOptions.
-type FormatOption int
+// FormatOption can be passed to Diff.Format. It's either one of the flag
+// constants below, a FormatOptions struct, a Theme, or returned by functions
+// like ContextLines and Width.
+type FormatOption interface {
+	applyFormat(config *asciiFormatterConfig)
+}
+
+// FormatOptions holds parameterized formatting settings. Zero fields keep
+// the defaults, so it can be combined with other FormatOptions:
+//
+//	diff.Format(left, jsondiff.Colored, jsondiff.FormatOptions{Indent: "\t"})
+type FormatOptions struct {
+	Indent         string // per nesting level, two spaces by default
+	UnquotedKeys   bool   // print keys that are identifiers without quotes
+	TrailingCommas bool   // print a comma after the last element as well
+	// Characters printed at the start of lines, AsciiSame, AsciiAdded,
+	// AsciiDeleted and AsciiMoved by default.
+	SameMarker, AddedMarker, DeletedMarker, MovedMarker string
+	// LineWidth truncates longer lines with an ellipsis. It's also the
+	// default width of SideBySide output.
+	LineWidth int
+}
+
+func (o FormatOptions) applyFormat(config *asciiFormatterConfig) {
+	if o.Indent != "" {
+		config.Indent = o.Indent
+	}
+	config.UnquotedKeys = config.UnquotedKeys || o.UnquotedKeys
+	config.TrailingCommas = config.TrailingCommas || o.TrailingCommas
+	for marker, printed := range map[string]string{
+		AsciiSame:    o.SameMarker,
+		AsciiAdded:   o.AddedMarker,
+		AsciiDeleted: o.DeletedMarker,
+		AsciiMoved:   o.MovedMarker,
+	} {
+		if printed != "" {
+			if config.Markers == nil {
+				config.Markers = make(map[string]string)
+			}
+			config.Markers[marker] = printed
+		}
+	}
+	if o.LineWidth > 0 {
+		config.LineWidth = o.LineWidth
+	}
+}
+
+type formatFlag int
 
 const (
-	ShowArrayIndex FormatOption = iota
+	// ShowArrayIndex labels array elements with their index: deleted
+	// elements with their index in the left array, others with their index
+	// in the right array.
+	ShowArrayIndex formatFlag = iota
 	Colored
 	HideUnchangedProperties
+	// UnescapedUnicode keeps non-ASCII characters in strings as is instead of
+	// escaping them as \uXXXX.
+	UnescapedUnicode
+	// DottedPaths makes FormatPaths write paths like `obj.items[3]["a b"]`
+	// instead of JSON Pointers.
+	DottedPaths
+	// SideBySide renders the left document and the right one in two columns,
+	// with changed lines next to each other. See also Width.
+	SideBySide
+	// StandaloneHTML makes FormatHTML produce a complete page with an
+	// embedded stylesheet.
+	StandaloneHTML
+	// InlineHighlight marks the changed characters within modified strings,
+	// as [-old-] and {+new+}, or in inverse video with Colored.
+	InlineHighlight
+	// MultilineStrings renders modified strings that span several lines as
+	// a line by line diff of their contents within """ delimiters.
+	MultilineStrings
 )
 
+func (flag formatFlag) applyFormat(config *asciiFormatterConfig) {
+	switch flag {
+	case ShowArrayIndex:
+		config.ShowArrayIndex = true
+	case Colored:
+		config.Coloring = true
+	case HideUnchangedProperties:
+		config.HideUnchangedProperties = true
+	case UnescapedUnicode:
+		config.UnescapedUnicode = true
+	case DottedPaths:
+		config.DottedPaths = true
+	case SideBySide:
+		config.SideBySide = true
+	case StandaloneHTML:
+		config.StandaloneHTML = true
+	case InlineHighlight:
+		config.InlineHighlight = true
+	case MultilineStrings:
+		config.MultilineStrings = true
+	}
+}
+
+// Format renders the diff as an annotated copy of the left document, which
+// may be any JSON value. Parts of the diff that don't match left are skipped
+// and the values there are shown unchanged; use Render to detect such
+// mismatches.
 func (diff Diff) Format(left any, opts ...FormatOption) string {
-	f := asciiFormatter{left: left}
+	s, _ := diff.Render(left, opts...)
+	return s
+}
+
+// Render is like Format, but also returns a *MismatchError describing the
+// first part of the diff that doesn't match left.
+func (diff Diff) Render(left any, opts ...FormatOption) (string, error) {
+	var buf strings.Builder
+	err := diff.RenderTo(&buf, left, opts...)
+	return strings.TrimSuffix(buf.String(), "\n"), err
+}
+
+// RenderTo is like Render, but streams newline-terminated lines to w as they
+// are produced, buffering writes unless w is a *bufio.Writer, *bytes.Buffer
+// or *strings.Builder. Write errors take precedence over mismatch errors.
+func (diff Diff) RenderTo(w io.Writer, left any, opts ...FormatOption) error {
+	return diff.render(w, left, false, opts)
+}
+
+func (diff Diff) render(w io.Writer, left any, html bool, opts []FormatOption) error {
+	f := asciiFormatter{left: left, config: asciiFormatterConfig{ContextLines: -1}}
 	for _, opt := range opts {
-		switch opt {
-		case ShowArrayIndex:
-			f.config.ShowArrayIndex = true
-		case Colored:
-			f.config.Coloring = true
-		case HideUnchangedProperties:
-			f.config.HideUnchangedProperties = true
+		opt.applyFormat(&f.config)
+	}
+	if f.config.Indent == "" {
+		f.config.Indent = "  "
+	}
+	f.theme = f.config.Theme
+	if f.theme == nil {
+		f.theme = themeFromStyles(AsciiStyles)
+	}
+	if f.config.ContextLines >= 0 {
+		f.context = &lineCollapser{context: f.config.ContextLines}
+	}
+	if html {
+		f.html = &htmlWriter{standalone: f.config.StandaloneHTML}
+	} else if f.config.SideBySide {
+		width := f.config.Width
+		if width == 0 {
+			width = f.config.LineWidth
 		}
+		f.sides = newSideBySide(width)
+	}
+	var bw *bufio.Writer
+	switch out := w.(type) {
+	case *bufio.Writer, *bytes.Buffer, *strings.Builder:
+		f.out = out.(output) // already buffered
+	default:
+		bw = bufio.NewWriter(w)
+		f.out = bw
+	}
+
+	if f.html != nil {
+		f.html.start(&f)
 	}
-	if v, ok := f.left.(map[string]any); ok {
+	if d, ok := rootModified(diff); ok {
+		f.modified = true
+		f.printRecursive("", d.OldValue, AsciiDeleted)
+		f.printRecursive("", d.NewValue, AsciiAdded)
+		f.modified = false
+	} else if v, ok := f.left.(map[string]any); ok {
 		f.formatObject(v, diff)
 	} else if v, ok := f.left.([]any); ok {
 		f.formatArray(v, diff)
 	} else {
-		panic(fmt.Errorf("expected map[string]any or []any, got %T",
-			f.left))
+		f.printRecursive("", f.left, AsciiSame)
+		if len(diff) > 0 {
+			f.mismatch(&MismatchError{"", diff[0], fmt.Sprintf("expected an object or an array, got %T", left)})
+		}
+	}
+	if f.context != nil {
+		f.context.finish(&f)
+	}
+	if f.sides != nil {
+		f.sides.finish(&f)
+	}
+	if f.html != nil {
+		f.html.finish(&f)
+	}
+	if bw != nil && f.err == nil {
+		f.err = bw.Flush()
+	}
+	if f.err != nil {
+		return f.err
+	}
+	if f.failure != nil {
+		return f.failure
+	}
+	return nil
+}
+
+// rootModified returns the delta replacing the whole document, if any.
+func rootModified(diff Diff) (*Modified, bool) {
+	if len(diff) == 1 {
+		if d, ok := diff[0].(*Modified); ok && d.Position == nil {
+			return d, true
+		}
 	}
-	return strings.TrimRight(f.buffer.String(), "\n")
+	return nil, false
 }
 
 type asciiFormatter struct {
 	left    any
 	config  asciiFormatterConfig
-	buffer  bytes.Buffer
+	out     output
+	err     error          // first write error
+	failure *MismatchError // first part of the diff that doesn't match left
 	path    []string
 	size    []int
 	inArray []bool
-	line    *asciiLine
+	line    asciiLine
+	lineBuf bytes.Buffer // reused by all lines
+	lineOut bytes.Buffer // rendered line, reused by all lines
+	note    string       // comment for the next line
+	only    lineSide     // restricts lines to one side in side-by-side output
+	context *lineCollapser
+	sides   *sideBySide
+	html    *htmlWriter
+	theme   *Theme
+	clipBuf []byte
+	// modified is set while printing the old and new values of a Modified
+	// delta
+	modified bool
+}
+
+type output interface {
+	io.Writer
+	io.StringWriter
 }
 
 type asciiFormatterConfig struct {
 	ShowArrayIndex          bool
 	Coloring                bool
 	HideUnchangedProperties bool
+	UnescapedUnicode        bool
+	InlineHighlight         bool
+	MultilineStrings        bool
+	ContextLines            int // -1 shows all lines
+	Theme                   *Theme
+	Indent                  string
+	UnquotedKeys            bool
+	TrailingCommas          bool
+	Markers                 map[string]string // printed characters by marker
+	LineWidth               int
+	DottedPaths             bool
+	SideBySide              bool
+	StandaloneHTML          bool
+	Width                   int // 0 uses the terminal width
 }
 
 type asciiLine struct {
 	marker string
 	indent int
 	buffer *bytes.Buffer
+	spans  []textSpan
+	note   string
+	raw    bool // content of a multi-line string, never a bracket
 }
 
 func (f *asciiFormatter) formatObject(left map[string]any, df Diff) {
@@ -78,30 +290,88 @@ func (f *asciiFormatter) formatArray(left []any, df Diff) {
 	f.addLineWith(AsciiSame, "]")
 }
 
-func (f *asciiFormatter) processArray(array []any, deltas []Delta) error {
-	patchedIndex := 0
-	for index, value := range array {
-		f.processItem(value, deltas, Index(index))
-		patchedIndex++
+// mismatch records the first part of the diff that doesn't match left.
+func (f *asciiFormatter) mismatch(err *MismatchError) {
+	if f.failure == nil {
+		f.failure = err
 	}
+}
 
-	// additional Added
-	for _, delta := range deltas {
-		switch delta.(type) {
-		case *Added:
-			d := delta.(*Added)
-			// skip items already processed
-			if int(d.Position.(Index)) < len(array) {
-				continue
-			}
-			f.printRecursive(d.Position.String(), d.Value, AsciiAdded)
+// skip records a mismatched delta and prints value as if it was unchanged.
+func (f *asciiFormatter) skip(err *MismatchError, positionStr string, value any) {
+	f.mismatch(err)
+	if !f.config.HideUnchangedProperties {
+		f.printRecursive(positionStr, value, AsciiSame)
+	}
+}
+
+func (f *asciiFormatter) processArray(array []any, deltas []Delta) {
+	items, _, err := alignArray(len(array), deltas)
+	if err != nil {
+		f.mismatch(&MismatchError{f.pointer(), nil, err.Error()})
+		for i, value := range array {
+			f.processItem(value, nil, Index(i))
 		}
+		return
 	}
 
-	return nil
+	// every item but the old position of a moved one is printed, so count
+	// them for commas
+	size := 0
+	for _, item := range items {
+		if item.kind != itemMovedFrom {
+			size++
+		}
+	}
+	f.size[len(f.size)-1] = size
+
+	for _, item := range items {
+		switch item.kind {
+		case itemKept:
+			// kept and added items are labeled with their right index
+			if item.change != nil {
+				f.processDeltas(array[item.left], []Delta{item.change}, Index(item.right).String(), AsciiSame)
+			} else if !f.config.HideUnchangedProperties {
+				f.printRecursive(Index(item.right).String(), array[item.left], AsciiSame)
+			}
+
+		case itemDeleted:
+			f.processDeltas(array[item.left], []Delta{item.delta}, Index(item.left).String(), AsciiSame)
+
+		case itemAdded:
+			f.processDeltas(nil, []Delta{item.delta}, Index(item.right).String(), AsciiSame)
+
+		case itemMovedFrom:
+			if d := item.delta.(*Moved); !reflect.DeepEqual(array[item.left], d.Value) {
+				f.mismatch(&MismatchError{f.pointer(Index(item.left).String()), d, fmt.Sprintf("expected %v to move, got %v", d.Value, array[item.left])})
+			} else if f.sides != nil {
+				// the left column shows moved items at their old position
+				savedSize := f.size[len(f.size)-1]
+				f.size[len(f.size)-1] = len(array) - item.left
+				f.only = sideLeft
+				f.note = fmt.Sprintf("moved to index %d", item.right)
+				f.printRecursive(Index(item.left).String(), array[item.left], AsciiMoved)
+				f.only = sideBoth
+				f.size[len(f.size)-1] = savedSize
+			}
+
+		case itemMovedTo:
+			// moved items are only shown at their new position
+			if f.sides != nil {
+				f.only = sideRight
+			}
+			f.note = fmt.Sprintf("moved from index %d", item.left)
+			if item.change != nil {
+				f.processDeltas(array[item.left], []Delta{item.change}, Index(item.right).String(), AsciiMoved)
+			} else {
+				f.printRecursive(Index(item.right).String(), array[item.left], AsciiMoved)
+			}
+			f.only = sideBoth
+		}
+	}
 }
 
-func (f *asciiFormatter) processObject(object map[string]any, deltas []Delta) error {
+func (f *asciiFormatter) processObject(object map[string]any, deltas []Delta) {
 	names := sortedKeys(object)
 	for _, name := range names {
 		value := object[name]
@@ -110,93 +380,120 @@ func (f *asciiFormatter) processObject(object map[string]any, deltas []Delta) er
 
 	// Added
 	for _, delta := range deltas {
+		name := positionOf(delta).String()
 		switch delta.(type) {
 		case *Added:
 			d := delta.(*Added)
 			f.printRecursive(d.Position.String(), d.Value, AsciiAdded)
+		default:
+			if _, ok := object[name]; !ok {
+				f.mismatch(&MismatchError{f.pointer(name), delta, "no such property"})
+			}
 		}
 	}
-
-	return nil
 }
 
-func (f *asciiFormatter) processItem(value any, deltas []Delta, position Position) error {
+func (f *asciiFormatter) processItem(value any, deltas []Delta, position Position) {
 	matchedDeltas := filterDeltasByPosition(deltas, position)
-	positionStr := position.String()
 	if len(matchedDeltas) > 0 {
-		for _, matchedDelta := range matchedDeltas {
-
-			switch matchedDelta.(type) {
-			case *Object:
-				d := matchedDelta.(*Object)
-				switch value.(type) {
-				case map[string]any:
-					//ok
-				default:
-					return errors.New("Type mismatch")
-				}
-				o := value.(map[string]any)
-
-				f.newLine(AsciiSame)
-				f.printKey(positionStr)
-				f.print("{")
-				f.closeLine()
-				f.push(positionStr, len(o), false)
-				f.processObject(o, d.Deltas)
-				f.pop()
-				f.newLine(AsciiSame)
-				f.print("}")
-				f.printComma()
-				f.closeLine()
-
-			case *Array:
-				d := matchedDelta.(*Array)
-				switch value.(type) {
-				case []any:
-					//ok
-				default:
-					return errors.New("Type mismatch")
-				}
-				a := value.([]any)
-
-				f.newLine(AsciiSame)
-				f.printKey(positionStr)
-				f.print("[")
-				f.closeLine()
-				f.push(positionStr, len(a), true)
-				f.processArray(a, d.Deltas)
-				f.pop()
-				f.newLine(AsciiSame)
-				f.print("]")
-				f.printComma()
-				f.closeLine()
-
-			case *Added:
-				d := matchedDelta.(*Added)
-				f.printRecursive(positionStr, d.Value, AsciiAdded)
-				f.size[len(f.size)-1]++
-
-			case *Modified:
-				d := matchedDelta.(*Modified)
-				savedSize := f.size[len(f.size)-1]
+		f.processDeltas(value, matchedDeltas, position.String(), AsciiSame)
+	} else if !f.config.HideUnchangedProperties {
+		f.printRecursive(position.String(), value, AsciiSame)
+	}
+}
+
+// processDeltas prints a value affected by the given deltas, using marker for
+// the lines opening and closing a changed object or array. Deltas that don't
+// match the value are recorded and skipped.
+func (f *asciiFormatter) processDeltas(value any, deltas []Delta, positionStr string, marker string) {
+	for _, matchedDelta := range deltas {
+
+		switch matchedDelta.(type) {
+		case *Object:
+			d := matchedDelta.(*Object)
+			o, ok := value.(map[string]any)
+			if !ok {
+				f.skip(&MismatchError{f.pointer(positionStr), d, fmt.Sprintf("expected an object, got %T", value)}, positionStr, value)
+				continue
+			}
+
+			f.newLine(marker)
+			f.printKey(positionStr)
+			f.print("{")
+			f.closeLine()
+			f.push(positionStr, len(o), false)
+			f.processObject(o, d.Deltas)
+			f.pop()
+			f.newLine(marker)
+			f.print("}")
+			f.printComma()
+			f.closeLine()
+
+		case *Array:
+			d := matchedDelta.(*Array)
+			a, ok := value.([]any)
+			if !ok {
+				f.skip(&MismatchError{f.pointer(positionStr), d, fmt.Sprintf("expected an array, got %T", value)}, positionStr, value)
+				continue
+			}
+
+			f.newLine(marker)
+			f.printKey(positionStr)
+			f.print("[")
+			f.closeLine()
+			f.push(positionStr, len(a), true)
+			f.processArray(a, d.Deltas)
+			f.pop()
+			f.newLine(marker)
+			f.print("]")
+			f.printComma()
+			f.closeLine()
+
+		case *Added:
+			d := matchedDelta.(*Added)
+			if !f.inArray[len(f.inArray)-1] {
+				f.skip(&MismatchError{f.pointer(positionStr), d, "cannot add, already exists"}, positionStr, value)
+				continue
+			}
+			f.printRecursive(positionStr, d.Value, AsciiAdded)
+
+		case *Modified:
+			d := matchedDelta.(*Modified)
+			if !reflect.DeepEqual(value, d.OldValue) {
+				f.skip(&MismatchError{f.pointer(positionStr), d, fmt.Sprintf("expected %v, got %v", d.OldValue, value)}, positionStr, value)
+				continue
+			}
+			savedSize := f.size[len(f.size)-1]
+			f.modified = true
+			if !f.printMultiline(positionStr, d.OldValue, d.NewValue) && !f.printHighlighted(positionStr, d.OldValue, d.NewValue, savedSize) {
 				f.printRecursive(positionStr, d.OldValue, AsciiDeleted)
 				f.size[len(f.size)-1] = savedSize
 				f.printRecursive(positionStr, d.NewValue, AsciiAdded)
+			}
+			f.modified = false
 
-			case *Deleted:
-				d := matchedDelta.(*Deleted)
-				f.printRecursive(positionStr, d.Value, AsciiDeleted)
-
-			default:
-				return errors.New("Unknown Delta type detected")
+		case *Deleted:
+			d := matchedDelta.(*Deleted)
+			if !reflect.DeepEqual(value, d.Value) {
+				f.skip(&MismatchError{f.pointer(positionStr), d, fmt.Sprintf("cannot delete, expected %v, got %v", d.Value, value)}, positionStr, value)
+				continue
 			}
+			f.printRecursive(positionStr, d.Value, AsciiDeleted)
 
+		default:
+			f.skip(&MismatchError{f.pointer(positionStr), matchedDelta, fmt.Sprintf("unexpected %T", matchedDelta)}, positionStr, value)
 		}
-	} else if !f.config.HideUnchangedProperties {
-		f.printRecursive(positionStr, value, AsciiSame)
+
 	}
+}
 
-	return nil
+// pointer returns the JSON Pointer of the current value or its child.
+func (f *asciiFormatter) pointer(child ...string) string {
+	var buf strings.Builder
+	for _, name := range append(f.path[1:len(f.path):len(f.path)], child...) {
+		buf.WriteString("/" + escapePointer(name))
+	}
+	return buf.String()
 }
 
 func filterDeltasByPosition(deltas []Delta, position Position) (results []Delta) {
@@ -213,11 +510,17 @@ const (
 	AsciiSame    = " "
 	AsciiAdded   = "+"
 	AsciiDeleted = "-"
+	AsciiMoved   = ">"
 )
 
+// AsciiStyles holds the SGR parameters used for lines with each marker when
+// no Theme is given.
+//
+// Deprecated: changing it affects all callers; pass a Theme instead.
 var AsciiStyles = map[string]string{
 	AsciiAdded:   "30;42",
 	AsciiDeleted: "30;41",
+	AsciiMoved:   "30;43",
 }
 
 func (f *asciiFormatter) push(name string, size int, array bool) {
@@ -233,69 +536,260 @@ func (f *asciiFormatter) pop() {
 }
 
 func (f *asciiFormatter) addLineWith(marker string, value string) {
-	f.line = &asciiLine{
-		marker: marker,
-		indent: len(f.path),
-		buffer: bytes.NewBufferString(value),
-	}
+	f.newLine(marker)
+	f.print(value)
 	f.closeLine()
 }
 
 func (f *asciiFormatter) newLine(marker string) {
-	f.line = &asciiLine{
+	f.lineBuf.Reset()
+	f.line = asciiLine{
 		marker: marker,
 		indent: len(f.path),
-		buffer: bytes.NewBuffer([]byte{}),
+		buffer: &f.lineBuf,
+		spans:  f.line.spans[:0],
+		note:   f.note,
 	}
+	f.note = ""
 }
 
 func (f *asciiFormatter) closeLine() {
-	style, ok := AsciiStyles[f.line.marker]
-	if f.config.Coloring && ok {
-		f.buffer.WriteString("\x1b[" + style + "m")
+	kind := lineLeaf
+	if !f.line.raw {
+		kind = lineKindOf(f.line.buffer.Bytes())
+	}
+	line := formattedLine{
+		marker:   f.line.marker,
+		indent:   f.line.indent,
+		kind:     kind,
+		side:     f.lineSide(f.line.marker),
+		text:     f.line.buffer.Bytes(),
+		spans:    f.line.spans,
+		note:     f.line.note,
+		modified: f.modified,
+	}
+	if line.side == sideNone {
+		return
 	}
+	if f.context != nil {
+		f.context.add(f, line)
+	} else {
+		f.emit(line)
+	}
+}
+
+// formattedLine is a line of output before indentation and coloring.
+type formattedLine struct {
+	marker    string
+	indent    int
+	text      []byte     // valid until the next line is started
+	spans     []textSpan // highlighted parts of text, also reused
+	note      string
+	kind      lineKind
+	side      lineSide
+	modified  bool // part of the old or new value of a Modified delta
+	collapsed bool // stands for unchanged lines left out by ContextLines
+}
 
-	f.buffer.WriteString(f.line.marker)
-	for n := 0; n < f.line.indent; n++ {
-		f.buffer.WriteString("  ")
+// lineKind tells whether a line opens or closes an object or array.
+type lineKind int
+
+const (
+	lineLeaf lineKind = iota
+	lineOpen
+	lineClose
+)
+
+// lineKindOf classifies a line by its text. Keys and scalars are quoted or
+// bare words, so only brackets can appear at either end; lines of
+// multi-line strings are raw and aren't classified.
+func lineKindOf(text []byte) lineKind {
+	switch {
+	case len(text) == 0:
+		return lineLeaf
+	case text[0] == '}' || text[0] == ']':
+		return lineClose
+	case text[len(text)-1] == '{' || text[len(text)-1] == '[':
+		return lineOpen
+	default:
+		return lineLeaf
 	}
-	f.buffer.Write(f.line.buffer.Bytes())
+}
+
+// lineSide tells which document a line belongs to in side-by-side output.
+type lineSide int
+
+const (
+	sideBoth lineSide = iota
+	sideLeft
+	sideRight
+	sideNone
+)
 
-	if f.config.Coloring && ok {
-		f.buffer.WriteString("\x1b[0m")
+func (f *asciiFormatter) lineSide(marker string) lineSide {
+	switch {
+	case f.only == sideLeft:
+		return sideLeft
+	case f.only == sideRight && marker == AsciiDeleted:
+		return sideNone
+	case f.only == sideRight || marker == AsciiAdded || marker == AsciiMoved:
+		return sideRight
+	case marker == AsciiDeleted:
+		return sideLeft
+	default:
+		return sideBoth
+	}
+}
+
+// emit writes a line to the output.
+func (f *asciiFormatter) emit(line formattedLine) {
+	if f.sides != nil {
+		f.sides.add(f, line)
+		return
+	}
+	if f.html != nil {
+		f.html.add(f, line)
+		return
+	}
+	out := &f.lineOut
+	out.Reset()
+	style := ""
+	if f.config.Coloring {
+		style = f.theme.lineStyle(line)
+	}
+	if style != "" {
+		out.WriteString("\x1b[" + style + "m")
+	}
+
+	if f.config.LineWidth > 0 {
+		line = f.clip(line)
+	}
+	out.WriteString(f.marker(line.marker))
+	for n := 0; n < line.indent; n++ {
+		out.WriteString(f.config.Indent)
+	}
+	open, close := highlightMarkers(line.marker)
+	line.eachSegment(func(text []byte, kind spanKind) {
+		if kind == spanText {
+			out.Write(text)
+		} else if !f.config.Coloring {
+			if kind == spanHighlight {
+				out.WriteString(open)
+				out.Write(text)
+				out.WriteString(close)
+			} else {
+				out.Write(text)
+			}
+		} else if spanStyle := f.theme.spanStyle(kind); spanStyle != "" {
+			out.WriteString("\x1b[" + spanStyle + "m")
+			out.Write(text)
+			out.WriteString("\x1b[0m")
+			if style != "" {
+				out.WriteString("\x1b[" + style + "m")
+			}
+		} else {
+			out.Write(text)
+		}
+	})
+	if line.note != "" {
+		out.WriteString(" // " + line.note)
+	}
+
+	if style != "" {
+		out.WriteString("\x1b[0m")
 	}
 
-	f.buffer.WriteRune('\n')
+	out.WriteByte('\n')
+	f.write(out.Bytes())
+}
+
+// marker returns the characters printed for the given marker.
+func (f *asciiFormatter) marker(marker string) string {
+	if m, ok := f.config.Markers[marker]; ok {
+		return m
+	}
+	return marker
+}
+
+// clip truncates the text of a line to fit LineWidth, ending it with an
+// ellipsis.
+func (f *asciiFormatter) clip(line formattedLine) formattedLine {
+	width := f.config.LineWidth - utf8.RuneCountInString(f.marker(line.marker)) - line.indent*utf8.RuneCountInString(f.config.Indent)
+	if line.note != "" {
+		width -= utf8.RuneCountInString(" // " + line.note)
+	}
+	width = max(width, 1)
+	if utf8.RuneCount(line.text) <= width {
+		return line
+	}
+	cut := 0
+	for n := 0; n < width-1; n++ {
+		_, size := utf8.DecodeRune(line.text[cut:])
+		cut += size
+	}
+	f.clipBuf = append(append(f.clipBuf[:0], line.text[:cut]...), "…"...)
+	line.text = f.clipBuf
+	spans := line.spans
+	line.spans = nil
+	for _, span := range spans {
+		if span.start < cut {
+			line.spans = append(line.spans, textSpan{span.start, min(span.end, cut), span.kind})
+		}
+	}
+	return line
+}
+
+func (f *asciiFormatter) write(b []byte) {
+	if f.err == nil {
+		_, f.err = f.out.Write(b)
+	}
+}
+
+func (f *asciiFormatter) writeString(s string) {
+	if f.err == nil {
+		_, f.err = f.out.WriteString(s)
+	}
 }
 
 func (f *asciiFormatter) printKey(name string) {
+	if len(f.inArray) == 0 {
+		return // root value
+	}
+	start := f.line.buffer.Len()
 	if !f.inArray[len(f.inArray)-1] {
-		fmt.Fprintf(f.line.buffer, `"%s": `, name)
+		if f.config.UnquotedKeys && isIdentifier(name) {
+			f.line.buffer.WriteString(name)
+		} else {
+			writeJSONString(f.line.buffer, name, f.config.UnescapedUnicode)
+		}
 	} else if f.config.ShowArrayIndex {
-		fmt.Fprintf(f.line.buffer, `%s: `, name)
+		f.line.buffer.WriteString(name)
+	} else {
+		return
 	}
+	f.addSpan(start, spanKey)
+	f.print(": ")
 }
 
 func (f *asciiFormatter) printComma() {
+	if len(f.size) == 0 {
+		return // root value
+	}
 	f.size[len(f.size)-1]--
-	if f.size[len(f.size)-1] > 0 {
-		f.line.buffer.WriteRune(',')
+	if f.size[len(f.size)-1] > 0 || f.config.TrailingCommas {
+		f.print(",")
 	}
 }
 
 func (f *asciiFormatter) printValue(value any) {
-	switch value.(type) {
-	case string:
-		fmt.Fprintf(f.line.buffer, `"%s"`, value)
-	case nil:
-		f.line.buffer.WriteString("null")
-	default:
-		fmt.Fprintf(f.line.buffer, `%#v`, value)
-	}
+	writeJSONScalar(f.line.buffer, value, f.config.UnescapedUnicode)
 }
 
+// print writes punctuation.
 func (f *asciiFormatter) print(a string) {
+	start := f.line.buffer.Len()
 	f.line.buffer.WriteString(a)
+	f.addSpan(start, spanPunctuation)
 }
 
 func (f *asciiFormatter) printRecursive(name string, value any, marker string) {
@@ -330,8 +824,8 @@ func (f *asciiFormatter) printRecursive(name string, value any, marker string) {
 		s := value.([]any)
 		size := len(s)
 		f.push("", size, true)
-		for _, item := range s {
-			f.printRecursive("", item, marker)
+		for i, item := range s {
+			f.printRecursive(Index(i).String(), item, marker)
 		}
 		f.pop()
 
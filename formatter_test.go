@@ -2,6 +2,10 @@ package jsondiff
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
 	"testing"
 )
 
@@ -104,6 +108,705 @@ func TestDiff(t *testing.T) {
 	}
 }
 
+func TestCompareArraysLeftovers(t *testing.T) {
+	tests := []struct {
+		name     string
+		left     string
+		right    string
+		expected string
+	}{
+		{"deleted_after_modified", `{"a": [{"a": 1, "b": 1, "c": 1}, {"d": 1}]}`, `{"a": [{"a": 1, "b": 1, "c": 2}]}`, "Object(0) Deleted(1)"},
+		{"added_after_modified", `{"a": [{"a": 1, "b": 1, "c": 1}]}`, `{"a": [{"a": 1, "b": 1, "c": 2}, {"d": 1}]}`, "Object(0) Added(1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var left, right map[string]any
+			ensure(json.Unmarshal([]byte(tt.left), &left))
+			ensure(json.Unmarshal([]byte(tt.right), &right))
+			actual := ""
+			for _, delta := range CompareObjects(left, right)[0].(*Array).Deltas {
+				if actual != "" {
+					actual += " "
+				}
+				switch d := delta.(type) {
+				case *Object:
+					actual += "Object(" + d.Position.String() + ")"
+				case *Deleted:
+					actual += "Deleted(" + d.Position.String() + ")"
+				case *Added:
+					actual += "Added(" + d.Position.String() + ")"
+				default:
+					actual += "unexpected"
+				}
+			}
+			if actual != tt.expected {
+				t.Errorf("** DELTAS:\n%s\n\nEXPECTED:\n%s", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDiffRoots(t *testing.T) {
+	tests := []struct {
+		name     string
+		left     string
+		right    string
+		expected string
+	}{
+		{
+			name:  "array",
+			left:  `[1, "two", {"three": 3}]`,
+			right: `[1, "two", {"three": 33}, 4]`,
+			expected: ` [
+   1,
+   "two",
+   {
+-    "three": 3
++    "three": 33
+   },
++  4
+ ]`,
+		},
+		{
+			name:     "same_scalar",
+			left:     `"foo"`,
+			right:    `"foo"`,
+			expected: ` "foo"`,
+		},
+		{
+			name:  "scalar",
+			left:  `42`,
+			right: `null`,
+			expected: `-42
++null`,
+		},
+		{
+			name:  "type_change",
+			left:  `{"a": 1}`,
+			right: `[1]`,
+			expected: `-{
+-  "a": 1
+-}
++[
++  1
++]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v1, v2 any
+			ensure(json.Unmarshal([]byte(tt.left), &v1))
+			ensure(json.Unmarshal([]byte(tt.right), &v2))
+			actual := Compare(v1, v2).Format(v1)
+			if actual != tt.expected {
+				t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatMoved(t *testing.T) {
+	tests := []struct {
+		name     string
+		left     string
+		right    string
+		opts     []CompareOption
+		format   []FormatOption
+		expected string
+	}{
+		{
+			name:  "rotate",
+			left:  `[1, 2, 3]`,
+			right: `[3, 1, 2]`,
+			expected: ` [
+>  3, // moved from index 2
+   1,
+   2
+ ]`,
+		},
+		{
+			name:  "swap_objects",
+			left:  `{"list": [{"a": 1}, "x", "y"]}`,
+			right: `{"list": ["x", "y", {"a": 1}]}`,
+			expected: ` {
+   "list": [
+     "x",
+     "y",
+>    { // moved from index 0
+>      "a": 1
+>    }
+   ]
+ }`,
+		},
+		{
+			name:  "moved_and_changed",
+			left:  `[{"id": 1, "v": "a"}, {"id": 2, "v": "b"}]`,
+			right: `[{"id": 2, "v": "b"}, {"id": 1, "v": "c"}]`,
+			opts:  []CompareOption{MatchArrayBy("", "id")},
+			expected: ` [
+>  { // moved from index 1
+>    "id": 2,
+>    "v": "b"
+>  },
+   {
+     "id": 1,
+-    "v": "a"
++    "v": "c"
+   }
+ ]`,
+		},
+		{
+			name:  "moved_with_changes",
+			left:  `[{"id": 1}, {"id": 2}, {"id": 3, "v": "a"}]`,
+			right: `[{"id": 3, "v": "b"}, {"id": 1}, {"id": 2}]`,
+			opts:  []CompareOption{MatchArrayBy("", "id")},
+			expected: ` [
+>  { // moved from index 2
+     "id": 3,
+-    "v": "a"
++    "v": "b"
+>  },
+   {
+     "id": 1
+   },
+   {
+     "id": 2
+   }
+ ]`,
+		},
+		{
+			name:   "show_index",
+			left:   `["a", "b", "c"]`,
+			right:  `["c", "a", "b"]`,
+			format: []FormatOption{ShowArrayIndex},
+			expected: ` [
+>  0: "c", // moved from index 2
+   1: "a",
+   2: "b"
+ ]`,
+		},
+		{
+			name:   "show_index_shifted",
+			left:   `["x", "a", "b"]`,
+			right:  `["a", "b"]`,
+			format: []FormatOption{ShowArrayIndex},
+			expected: ` [
+-  0: "x",
+   0: "a",
+   1: "b"
+ ]`,
+		},
+		{
+			name:  "replaced_before_kept",
+			left:  `[1, 2, 9]`,
+			right: `[1, 3, 9]`,
+			opts:  []CompareOption{SimilarityThreshold(0.99)},
+			expected: ` [
+   1,
+-  2,
++  3,
+   9
+ ]`,
+		},
+		{
+			name:  "replaced_record",
+			left:  `[{"id": 1}, {"id": 2}]`,
+			right: `[{"id": 3}, {"id": 2}]`,
+			opts:  []CompareOption{MatchArrayBy("", "id")},
+			expected: ` [
+-  {
+-    "id": 1
+-  },
++  {
++    "id": 3
++  },
+   {
+     "id": 2
+   }
+ ]`,
+		},
+		{
+			name:  "appended",
+			left:  `["x", "b", "c"]`,
+			right: `["c", "b", "y"]`,
+			opts:  []CompareOption{UnorderedArrays()},
+			expected: ` [
+-  "x",
+   "b",
+   "c",
++  "y"
+ ]`,
+		},
+		{
+			name:   "colored",
+			left:   `[1, 2]`,
+			right:  `[2, 1]`,
+			format: []FormatOption{Colored},
+			expected: " [\n" +
+				"\x1b[30;43m>  2, // moved from index 1\x1b[0m\n" +
+				"   1\n" +
+				" ]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, right := parseTestJSON(tt.left), parseTestJSON(tt.right)
+			actual := Compare(left, right, tt.opts...).Format(left, tt.format...)
+			if actual != tt.expected {
+				t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderMismatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		left  string
+		diff  Diff
+		path  string
+		delta string
+	}{
+		{"type", `{"a": [1]}`, Diff{NewObject(Name("a"), []Delta{NewDeleted(Name("b"), 1)})}, "/a", "*jsondiff.Object"},
+		{"missing", `{"a": {}}`, Diff{NewObject(Name("a"), []Delta{NewModified(Name("b/c"), 1, 2)})}, "/a/b~1c", "*jsondiff.Modified"},
+		{"value", `{"a": [1, 2]}`, Diff{NewArray(Name("a"), []Delta{NewDeleted(Index(1), 3)})}, "/a/1", "*jsondiff.Deleted"},
+		{"index", `[1]`, Diff{NewDeleted(Index(5), 1)}, "", "<nil>"},
+		{"scalar", `1`, Diff{NewDeleted(Name("a"), 1)}, "", "*jsondiff.Deleted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.diff.Render(parseTestJSON(tt.left))
+			var me *MismatchError
+			if !errors.As(err, &me) {
+				t.Fatalf("Render error = %v, expected *MismatchError", err)
+			}
+			if me.Path != tt.path || fmt.Sprintf("%T", me.Delta) != tt.delta {
+				t.Errorf("Render error at %q with %T, expected %q with %s", me.Path, me.Delta, tt.path, tt.delta)
+			}
+		})
+	}
+}
+
+func TestRenderPastMismatch(t *testing.T) {
+	left := parseTestJSON(`{"a": 1, "b": 2, "c": [1]}`)
+	diff := Diff{NewModified(Name("a"), 5, 6), NewModified(Name("b"), 2.0, 3.0), NewObject(Name("c"), []Delta{NewDeleted(Name("d"), 1)})}
+	expected := ` {
+   "a": 1,
+-  "b": 2,
++  "b": 3,
+   "c": [
+     1
+   ]
+ }`
+	actual, err := diff.Render(left)
+	if actual != expected {
+		t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, expected)
+	}
+	var me *MismatchError
+	if !errors.As(err, &me) || me.Path != "/a" {
+		t.Errorf("Render error = %v, expected the mismatch at /a", err)
+	}
+	if actual := diff.Format(left); actual != expected {
+		t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, expected)
+	}
+}
+
+type limitedWriter struct {
+	buf   strings.Builder
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.buf.Len()+len(p) > w.limit {
+		return 0, errWriteLimit
+	}
+	return w.buf.Write(p)
+}
+
+var errWriteLimit = errors.New("write limit exceeded")
+
+func TestRenderTo(t *testing.T) {
+	left := parseTestJSON(`{"a": [1, 2, 3], "b": {"c": "d"}}`)
+	right := parseTestJSON(`{"a": [1, 3, 4], "b": {"c": "e"}}`)
+	diff := Compare(left, right)
+	expected, _ := diff.Render(left)
+
+	var buf strings.Builder
+	if err := diff.RenderTo(&buf, left); err != nil {
+		t.Fatalf("RenderTo error = %v", err)
+	}
+	if actual := buf.String(); actual != expected+"\n" {
+		t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, expected)
+	}
+
+	w := &limitedWriter{limit: 10}
+	if err := diff.RenderTo(w, left); err != errWriteLimit {
+		t.Errorf("RenderTo error = %v, expected %v", err, errWriteLimit)
+	}
+
+	// string writers like *os.File are buffered as well
+	for _, html := range []bool{false, true} {
+		cw := &countingWriter{}
+		if err := diff.render(cw, left, html, nil); err != nil {
+			t.Fatalf("render error = %v", err)
+		}
+		if cw.writes != 1 {
+			t.Errorf("render (html=%v) made %d writes, expected 1", html, cw.writes)
+		}
+	}
+}
+
+type countingWriter struct {
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return len(p), nil
+}
+
+func (w *countingWriter) WriteString(s string) (int, error) {
+	w.writes++
+	return len(s), nil
+}
+
+func TestContextLines(t *testing.T) {
+	left := parseTestJSON(`{"a": 1, "b": 2, "c": 3, "d": 4, "e": {"f": 5, "g": 6, "h": 7}, "i": 8}`)
+	right := parseTestJSON(`{"a": 1, "b": 2, "c": 3, "d": 4, "e": {"f": 5, "g": 66, "h": 7}, "i": 8}`)
+	tests := []struct {
+		name     string
+		context  int
+		expected string
+	}{
+		{"one", 1, ` ... 6 unchanged lines ...
+     "f": 5,
+-    "g": 6,
++    "g": 66,
+     "h": 7
+   ... 3 unchanged lines ...`},
+		{"zero", 0, ` ... 7 unchanged lines ...
+-    "g": 6,
++    "g": 66,
+     ... 4 unchanged lines ...`},
+		{"single_hidden_line_shown", 6, ` {
+   "a": 1,
+   "b": 2,
+   "c": 3,
+   "d": 4,
+   "e": {
+     "f": 5,
+-    "g": 6,
++    "g": 66,
+     "h": 7
+   },
+   "i": 8
+ }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := Compare(left, right).Format(left, ContextLines(tt.context))
+			if actual != tt.expected {
+				t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatValues(t *testing.T) {
+	left := map[string]any{
+		"quote\"key": "line\nbreak \"quoted\" back\\slash \x01 \u00e9 \U0001F600",
+		"int64":      int64(5),
+		"uint8":      uint8(7),
+		"float":      39.39,
+		"big":        1e21,
+		"small":      1e-7,
+		"float32":    float32(0.1),
+		"nan":        math.NaN(),
+		"number":     json.Number("12.50"),
+		"strings":    []string{"a<b"},
+		"bool":       false,
+	}
+	tests := []struct {
+		name     string
+		opts     []FormatOption
+		expected string
+	}{
+		{
+			name: "escaped",
+			expected: ` {
+   "big": 1e+21,
+   "bool": false,
+   "float": 39.39,
+   "float32": 0.1,
+   "int64": 5,
+   "nan": "NaN",
+   "number": 12.50,
+   "quote\"key": "line\nbreak \"quoted\" back\\slash \u0001 \u00e9 \ud83d\ude00",
+   "small": 1e-7,
+   "strings": ["a<b"],
+   "uint8": 7
+ }`,
+		},
+		{
+			name: "unescaped",
+			opts: []FormatOption{UnescapedUnicode},
+			expected: ` {
+   "big": 1e+21,
+   "bool": false,
+   "float": 39.39,
+   "float32": 0.1,
+   "int64": 5,
+   "nan": "NaN",
+   "number": 12.50,
+   "quote\"key": "line\nbreak \"quoted\" back\\slash \u0001 é 😀",
+   "small": 1e-7,
+   "strings": ["a<b"],
+   "uint8": 7
+ }`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := Diff{}.Format(left, tt.opts...)
+			if actual != tt.expected {
+				t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatPaths(t *testing.T) {
+	left := parseTestJSON(`{"obj": {"num": 19, "a b": 1}, "null": null, "arr": [0, 1, 2, {"x": 3}], "é": 1}`)
+	right := parseTestJSON(`{"obj": {"num": 9999, "a b": 1, "new": "added"}, "arr": [0, {"x": 3}, 1, 2], "é": 2}`)
+	diff := Compare(left, right)
+	tests := []struct {
+		name     string
+		diff     Diff
+		opts     []FormatOption
+		expected string
+	}{
+		{"pointer", diff, nil, `↔ /arr/3 → /arr/1
+- /null: null
+~ /obj/num: 19 → 9999
++ /obj/new: "added"
+~ /é: 1 → 2`},
+		{"dotted", diff, []FormatOption{DottedPaths, UnescapedUnicode}, `↔ arr[3] → arr[1]
+- null: null
+~ obj.num: 19 → 9999
++ obj.new: "added"
+~ ["é"]: 1 → 2`},
+		{"root", Compare(1, "a"), nil, `~ (root): 1 → "a"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := tt.diff.FormatPaths(tt.opts...)
+			if actual != tt.expected {
+				t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	left := parseTestJSON(`{"arr": [1, {"x": 1}, 2], "e": {"g": 6}, "i": 8, "long": "abcdefghijklmnopqrstuvwxyz"}`)
+	right := parseTestJSON(`{"arr": [{"x": 1}, 1, 2], "e": {"g": 66}, "j": 8, "long": "abcdefghijklmnopqrstuvwxyz"}`)
+	expected := ` {                        |  {
+   "arr": [               |    "arr": [
+                          | >    { // moved from ind…
+                          | >      "x": 1
+                          | >    },
+     1,                   |      1,
+>    { // moved to index… |
+>      "x": 1             |
+>    },                   |
+     2                    |      2
+   ],                     |    ],
+   "e": {                 |    "e": {
+-    "g": 6               | +    "g": 66
+   },                     |    },
+-  "i": 8,                |
+   "long": "abcdefghijkl… |    "long": "abcdefghijkl…
+                          | +  "j": 8
+ }                        |  }`
+	actual := Compare(left, right).Format(left, SideBySide, Width(53))
+	if actual != expected {
+		t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, expected)
+	}
+}
+
+func TestFormatHTML(t *testing.T) {
+	left := parseTestJSON(`{"a": "<b>", "e": {"f": 5}}`)
+	right := parseTestJSON(`{"a": "<i>", "e": {"f": 5, "g": true}}`)
+	expected := `<div class="jsondiff">
+<details open class="jd-unchanged"><summary><span class="jd-line jd-unchanged"><span class="jd-marker"> </span>{</span></summary>
+<div class="jd-line jd-modified jd-deleted"><span class="jd-marker">-</span>  "a": "&lt;b&gt;",</div>
+<div class="jd-line jd-modified jd-added"><span class="jd-marker">+</span>  "a": "&lt;i&gt;",</div>
+<details open class="jd-unchanged"><summary><span class="jd-line jd-unchanged"><span class="jd-marker"> </span>  "e": {</span></summary>
+<div class="jd-line jd-unchanged"><span class="jd-marker"> </span>    "f": 5</div>
+<div class="jd-line jd-added"><span class="jd-marker">+</span>    "g": true</div>
+<div class="jd-line jd-unchanged"><span class="jd-marker"> </span>  }</div>
+</details>
+<div class="jd-line jd-unchanged"><span class="jd-marker"> </span>}</div>
+</details>
+</div>
+`
+	diff := Compare(left, right)
+	if actual := diff.FormatHTML(left); actual != expected {
+		t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, expected)
+	}
+	page := diff.FormatHTML(left, StandaloneHTML)
+	if !strings.HasPrefix(page, "<!DOCTYPE html>") || !strings.Contains(page, HTMLStylesheet) || !strings.Contains(page, expected) {
+		t.Errorf("standalone page doesn't embed the stylesheet and markup:\n%s", page)
+	}
+}
+
+func TestInlineHighlight(t *testing.T) {
+	left := parseTestJSON(`{"a": "the quick brown fox", "b": "x", "c": "n=1"}`)
+	right := parseTestJSON(`{"a": "the quick red fox", "b": "y", "c": "n=2"}`)
+	tests := []struct {
+		name     string
+		opts     []FormatOption
+		expected string
+	}{
+		{"brackets", []FormatOption{InlineHighlight}, ` {
+-  "a": "the quick [-brown-] fox",
++  "a": "the quick {+red+} fox",
+-  "b": "x",
++  "b": "y",
+-  "c": "n=[-1-]"
++  "c": "n={+2+}"
+ }`},
+		{"colored", []FormatOption{InlineHighlight, Colored, HideUnchangedProperties}, " {\n" +
+			"\x1b[30;41m-  \"a\": \"the quick \x1b[7mbrown\x1b[0m\x1b[30;41m fox\",\x1b[0m\n" +
+			"\x1b[30;42m+  \"a\": \"the quick \x1b[7mred\x1b[0m\x1b[30;42m fox\",\x1b[0m\n" +
+			"\x1b[30;41m-  \"b\": \"x\",\x1b[0m\n" +
+			"\x1b[30;42m+  \"b\": \"y\",\x1b[0m\n" +
+			"\x1b[30;41m-  \"c\": \"n=\x1b[7m1\x1b[0m\x1b[30;41m\"\x1b[0m\n" +
+			"\x1b[30;42m+  \"c\": \"n=\x1b[7m2\x1b[0m\x1b[30;42m\"\x1b[0m\n" +
+			" }"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := Compare(left, right).Format(left, tt.opts...)
+			if actual != tt.expected {
+				t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMultilineStrings(t *testing.T) {
+	left := map[string]any{"sql": "SELECT *\nFROM a\nWHERE x = \"\t\"\n", "z": 1}
+	right := map[string]any{"sql": "SELECT *\nFROM b\nWHERE x = \"\t\"\nLIMIT 1\n", "z": 1}
+	expected := ` {
+   "sql": """
+     SELECT *
+-    FROM a
++    FROM b
+     WHERE x = "	"
++    LIMIT 1
+   """,
+   "z": 1
+ }`
+	actual := Compare(left, right).Format(left, MultilineStrings)
+	if actual != expected {
+		t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, expected)
+	}
+
+	// single-line strings are rendered as usual
+	actual = Compare(map[string]any{"a": "x\ny"}, map[string]any{"a": "z"}).Format(map[string]any{"a": "x\ny"}, MultilineStrings)
+	expected = ` {
+-  "a": "x\ny"
++  "a": "z"
+ }`
+	if actual != expected {
+		t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, expected)
+	}
+
+	// brackets within the string don't open or close HTML blocks
+	left = map[string]any{"code": "func() {\n\treturn 1\n}\n"}
+	right = map[string]any{"code": "func() {\n\treturn 2\n}\n"}
+	expected = `<div class="jsondiff">
+<details open class="jd-unchanged"><summary><span class="jd-line jd-unchanged"><span class="jd-marker"> </span>{</span></summary>
+<div class="jd-line jd-unchanged"><span class="jd-marker"> </span>  "code": """</div>
+<div class="jd-line jd-unchanged"><span class="jd-marker"> </span>    func() {</div>
+<div class="jd-line jd-modified jd-deleted"><span class="jd-marker">-</span>    	return 1</div>
+<div class="jd-line jd-modified jd-added"><span class="jd-marker">+</span>    	return 2</div>
+<div class="jd-line jd-unchanged"><span class="jd-marker"> </span>    }</div>
+<div class="jd-line jd-unchanged"><span class="jd-marker"> </span>  """</div>
+<div class="jd-line jd-unchanged"><span class="jd-marker"> </span>}</div>
+</details>
+</div>
+`
+	if actual := Compare(left, right).FormatHTML(left, MultilineStrings); actual != expected {
+		t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, expected)
+	}
+}
+
+func TestThemes(t *testing.T) {
+	left := parseTestJSON(`{"a": 1, "b": [true]}`)
+	right := parseTestJSON(`{"a": 2, "b": [true]}`)
+	diff := Compare(left, right)
+	theme := Theme{Added: "32", Deleted: "31", Unchanged: "2", Key: "34", Punctuation: "90"}
+	expected := "\x1b[2m \x1b[90m{\x1b[0m\x1b[2m\x1b[0m\n" +
+		"\x1b[31m-  \x1b[34m\"a\"\x1b[0m\x1b[31m\x1b[90m: \x1b[0m\x1b[31m1\x1b[90m,\x1b[0m\x1b[31m\x1b[0m\n" +
+		"\x1b[32m+  \x1b[34m\"a\"\x1b[0m\x1b[32m\x1b[90m: \x1b[0m\x1b[32m2\x1b[90m,\x1b[0m\x1b[32m\x1b[0m\n" +
+		"\x1b[2m   \x1b[34m\"b\"\x1b[0m\x1b[2m\x1b[90m: \x1b[0m\x1b[2m\x1b[90m[\x1b[0m\x1b[2m\x1b[0m\n" +
+		"\x1b[2m     true\x1b[0m\n" +
+		"\x1b[2m   \x1b[90m]\x1b[0m\x1b[2m\x1b[0m\n" +
+		"\x1b[2m \x1b[90m}\x1b[0m\x1b[2m\x1b[0m"
+	if actual := diff.Format(left, theme); actual != expected {
+		t.Errorf("** DIFF:\n%q\n\nEXPECTED:\n%q", actual, expected)
+	}
+
+	// presets don't depend on the global styles
+	saved := AsciiStyles[AsciiAdded]
+	AsciiStyles[AsciiAdded] = "45"
+	defer func() { AsciiStyles[AsciiAdded] = saved }()
+	if actual := diff.Format(left, BackgroundTheme); !strings.Contains(actual, "\x1b[30;42m+") {
+		t.Errorf("BackgroundTheme output uses global styles:\n%q", actual)
+	}
+}
+
+func TestFormatOptions(t *testing.T) {
+	left := parseTestJSON(`{"a": 1, "b c": [true, "a long string value"]}`)
+	right := parseTestJSON(`{"a": 2, "b c": [true, "a long string value"]}`)
+	tests := []struct {
+		name     string
+		opts     []FormatOption
+		expected string
+	}{
+		{"indent_and_keys", []FormatOption{FormatOptions{Indent: "\t", UnquotedKeys: true, TrailingCommas: true}}, ` {
+-	a: 1,
++	a: 2,
+ 	"b c": [
+ 		true,
+ 		"a long string value",
+ 	],
+ }`},
+		{"markers", []FormatOption{FormatOptions{SameMarker: "  ", AddedMarker: "> ", DeletedMarker: "< "}, HideUnchangedProperties}, `  {
+<   "a": 1,
+>   "a": 2,
+  }`},
+		{"line_width", []FormatOption{ShowArrayIndex, FormatOptions{LineWidth: 16}}, ` {
+-  "a": 1,
++  "a": 2,
+   "b c": [
+     0: true,
+     1: "a long…
+   ]
+ }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := Compare(left, right).Format(left, tt.opts...)
+			if actual != tt.expected {
+				t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, tt.expected)
+			}
+		})
+	}
+}
+
 func diff(left, right string) string {
 	var v1, v2 map[string]any
 	ensure(json.Unmarshal([]byte(left), &v1))
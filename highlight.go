@@ -0,0 +1,146 @@
+package jsondiff
+
+// maxHighlightCells bounds the size of the LCS table for inline
+// highlighting; longer strings are shown without highlights.
+const maxHighlightCells = 1 << 20
+
+// textSpan is a byte range within the text of a line.
+type textSpan struct {
+	start, end int
+	kind       spanKind
+}
+
+type spanKind int
+
+const (
+	spanText spanKind = iota
+	spanHighlight
+	spanKey
+	spanPunctuation
+)
+
+// addSpan marks the text of the current line written since start. Keys and
+// punctuation are only tracked when the theme styles them.
+func (f *asciiFormatter) addSpan(start int, kind spanKind) {
+	if kind != spanHighlight && (!f.config.Coloring || f.theme.spanStyle(kind) == "") {
+		return
+	}
+	if end := f.line.buffer.Len(); end > start {
+		f.line.spans = append(f.line.spans, textSpan{start, end, kind})
+	}
+}
+
+// eachSegment calls fn for consecutive parts of the line's text with the
+// kind of span each part belongs to.
+func (line formattedLine) eachSegment(fn func(text []byte, kind spanKind)) {
+	pos := 0
+	for _, span := range line.spans {
+		if span.start > pos {
+			fn(line.text[pos:span.start], spanText)
+		}
+		fn(line.text[span.start:span.end], span.kind)
+		pos = span.end
+	}
+	if pos < len(line.text) || len(line.spans) == 0 {
+		fn(line.text[pos:], spanText)
+	}
+}
+
+// highlightMarkers returns the text surrounding highlighted spans in
+// uncolored output.
+func highlightMarkers(marker string) (open, close string) {
+	if marker == AsciiAdded {
+		return "{+", "+}"
+	}
+	return "[-", "-]"
+}
+
+// printHighlighted prints a modified string value as a deleted and an added
+// line with the changed characters highlighted. It returns false if the
+// values aren't strings or have nothing in common, leaving the printing to
+// the caller.
+func (f *asciiFormatter) printHighlighted(name string, oldValue, newValue any, size int) bool {
+	if !f.config.InlineHighlight {
+		return false
+	}
+	oldString, ok := oldValue.(string)
+	if !ok {
+		return false
+	}
+	newString, ok := newValue.(string)
+	if !ok {
+		return false
+	}
+	oldRunes, newRunes := []rune(oldString), []rune(newString)
+	if len(oldRunes)*len(newRunes) > maxHighlightCells {
+		return false
+	}
+	pairs := lcsIndexPairs(oldRunes, newRunes, func(a, b rune) bool { return a == b })
+	pairs = dropShortRuns(pairs, len(oldRunes), len(newRunes))
+	if len(pairs) == 0 {
+		return false
+	}
+	oldKept, newKept := make([]bool, len(oldRunes)), make([]bool, len(newRunes))
+	for _, pair := range pairs {
+		oldKept[pair.Left] = true
+		newKept[pair.Right] = true
+	}
+
+	f.newLine(AsciiDeleted)
+	f.printKey(name)
+	f.printHighlightedString(oldRunes, oldKept)
+	f.printComma()
+	f.closeLine()
+	f.size[len(f.size)-1] = size
+	f.newLine(AsciiAdded)
+	f.printKey(name)
+	f.printHighlightedString(newRunes, newKept)
+	f.printComma()
+	f.closeLine()
+	return true
+}
+
+// minHighlightRun is the length of the shortest unchanged run kept between
+// two changes. Shorter runs, like the "r" shared by "brown" and "red", are
+// merged into the surrounding changes to avoid fragmented highlights.
+const minHighlightRun = 3
+
+// dropShortRuns removes runs of consecutive pairs shorter than
+// minHighlightRun, except at the start and the end of the strings.
+func dropShortRuns(pairs []lcsIndexPair, leftLen, rightLen int) []lcsIndexPair {
+	kept := pairs[:0]
+	for i := 0; i < len(pairs); {
+		j := i + 1
+		for j < len(pairs) && pairs[j].Left == pairs[j-1].Left+1 && pairs[j].Right == pairs[j-1].Right+1 {
+			j++
+		}
+		first, last := pairs[i], pairs[j-1]
+		atStart := first.Left == 0 && first.Right == 0
+		atEnd := last.Left == leftLen-1 && last.Right == rightLen-1
+		if j-i >= minHighlightRun || atStart || atEnd {
+			kept = append(kept, pairs[i:j]...)
+		}
+		i = j
+	}
+	return kept
+}
+
+// printHighlightedString prints a string literal, highlighting the runes
+// that aren't kept.
+func (f *asciiFormatter) printHighlightedString(runes []rune, kept []bool) {
+	buf := f.line.buffer
+	buf.WriteByte('"')
+	for i := 0; i < len(runes); {
+		j := i + 1
+		for j < len(runes) && kept[j] == kept[i] {
+			j++
+		}
+		start := buf.Len()
+		writeJSONStringContent(buf, string(runes[i:j]), f.config.UnescapedUnicode)
+		if !kept[i] {
+			f.addSpan(start, spanHighlight)
+		}
+		i = j
+	}
+	buf.WriteByte('"')
+}
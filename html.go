@@ -0,0 +1,135 @@
+package jsondiff
+
+import (
+	"io"
+	"strings"
+)
+
+// HTMLStylesheet styles the markup produced by FormatHTML. It's embedded in
+// standalone pages; include it yourself when embedding fragments.
+const HTMLStylesheet = `.jsondiff { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; font-size: 13px; line-height: 1.4; }
+.jsondiff .jd-line { white-space: pre; }
+.jsondiff details > summary { list-style: none; cursor: pointer; }
+.jsondiff details > summary::-webkit-details-marker { display: none; }
+.jsondiff details:not([open]) > summary::after { content: " …"; color: #888; }
+.jsondiff .jd-marker { display: inline-block; width: 1.5em; color: #888; user-select: none; }
+.jsondiff .jd-added { background: #e6ffec; }
+.jsondiff .jd-deleted { background: #ffebe9; }
+.jsondiff .jd-modified.jd-deleted { background: #fff1e5; }
+.jsondiff .jd-modified.jd-added { background: #fff8c5; }
+.jsondiff .jd-moved { background: #ddf4ff; }
+.jsondiff .jd-note { color: #888; }
+.jsondiff del, .jsondiff ins { text-decoration: none; font-weight: bold; }
+.jsondiff del { background: #ffcecb; }
+.jsondiff ins { background: #abf2bc; }
+`
+
+// FormatHTML renders the diff like Format, as HTML markup. Lines are
+// <div class="jd-line"> elements with one of the classes jd-unchanged,
+// jd-added, jd-deleted or jd-moved, plus jd-modified for the old and new
+// values of a Modified delta. Objects and arrays are collapsible <details>
+// elements. Pass StandaloneHTML to get a complete page.
+func (diff Diff) FormatHTML(left any, opts ...FormatOption) string {
+	var buf strings.Builder
+	diff.RenderHTMLTo(&buf, left, opts...)
+	return buf.String()
+}
+
+// RenderHTMLTo is like FormatHTML, but streams the markup to w and reports
+// errors like RenderTo.
+func (diff Diff) RenderHTMLTo(w io.Writer, left any, opts ...FormatOption) error {
+	return diff.render(w, left, true, opts)
+}
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// htmlWriter turns the line stream into nested HTML elements.
+type htmlWriter struct {
+	standalone bool
+	open       []int // indentation of the enclosing <details> elements
+	buf        strings.Builder
+}
+
+func (h *htmlWriter) start(f *asciiFormatter) {
+	if h.standalone {
+		f.writeString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>JSON diff</title>\n<style>\n" +
+			HTMLStylesheet + "</style>\n</head>\n<body>\n")
+	}
+	f.writeString("<div class=\"jsondiff\">\n")
+}
+
+func (h *htmlWriter) finish(f *asciiFormatter) {
+	for range h.open {
+		f.writeString("</details>\n")
+	}
+	h.open = nil
+	f.writeString("</div>\n")
+	if h.standalone {
+		f.writeString("</body>\n</html>\n")
+	}
+}
+
+func (h *htmlWriter) add(f *asciiFormatter, line formattedLine) {
+	// close blocks whose closing line was collapsed by ContextLines
+	for n := len(h.open); n > 0 && (h.open[n-1] > line.indent || h.open[n-1] == line.indent && line.kind != lineClose); n-- {
+		f.writeString("</details>\n")
+		h.open = h.open[:n-1]
+	}
+
+	class := h.class(line)
+	switch {
+	case line.kind == lineOpen:
+		f.writeString("<details open class=\"" + class + "\"><summary>")
+		h.writeLine(f, class, line, "span")
+		f.writeString("</summary>\n")
+		h.open = append(h.open, line.indent)
+	case line.kind == lineClose && len(h.open) > 0 && h.open[len(h.open)-1] == line.indent:
+		h.writeLine(f, class, line, "div")
+		f.writeString("\n</details>\n")
+		h.open = h.open[:len(h.open)-1]
+	default:
+		h.writeLine(f, class, line, "div")
+		f.writeString("\n")
+	}
+}
+
+func (h *htmlWriter) class(line formattedLine) string {
+	class := "jd-unchanged"
+	switch line.marker {
+	case AsciiAdded:
+		class = "jd-added"
+	case AsciiDeleted:
+		class = "jd-deleted"
+	case AsciiMoved:
+		class = "jd-moved"
+	}
+	if line.modified {
+		class = "jd-modified " + class
+	}
+	return class
+}
+
+func (h *htmlWriter) writeLine(f *asciiFormatter, class string, line formattedLine, tag string) {
+	b := &h.buf
+	b.Reset()
+	b.WriteString("<" + tag + " class=\"jd-line " + class + "\"><span class=\"jd-marker\">")
+	b.WriteString(htmlEscaper.Replace(f.marker(line.marker)))
+	b.WriteString("</span>")
+	b.WriteString(strings.Repeat(f.config.Indent, line.indent))
+	mark := "del"
+	if line.marker == AsciiAdded {
+		mark = "ins"
+	}
+	line.eachSegment(func(text []byte, kind spanKind) {
+		if kind == spanHighlight {
+			b.WriteString("<" + mark + ">" + htmlEscaper.Replace(string(text)) + "</" + mark + ">")
+		} else {
+			b.WriteString(htmlEscaper.Replace(string(text)))
+		}
+	})
+	if line.note != "" {
+		b.WriteString(" <span class=\"jd-note\">// " + htmlEscaper.Replace(line.note) + "</span>")
+	}
+	b.WriteString("</" + tag + ">")
+	f.writeString(b.String())
+}
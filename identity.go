@@ -0,0 +1,117 @@
+package jsondiff
+
+import "reflect"
+
+// IdentityFunc returns the identity of an array element, or false if the
+// element has none. Identities that aren't comparable with == are treated
+// as missing.
+type IdentityFunc func(element any) (id any, ok bool)
+
+// MatchArrayBy pairs elements of the arrays matching the path pattern (see
+// IgnorePattern) by the value of their key member, e.g.
+// MatchArrayBy("/users", "id"). Paired elements that changed produce Object
+// deltas, and reordered ones produce Moved deltas. Elements without a scalar
+// key, or whose identity has no counterpart, are reported as deleted or added.
+func MatchArrayBy(pattern, key string) CompareOption {
+	return MatchArrayByFunc(pattern, func(element any) (any, bool) {
+		object, ok := element.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		switch id := object[key].(type) {
+		case nil, map[string]any, []any:
+			return nil, false
+		default:
+			return id, true
+		}
+	})
+}
+
+// MatchArrayByFunc is like MatchArrayBy, but computes element identities
+// with the given function.
+func MatchArrayByFunc(pattern string, identity IdentityFunc) CompareOption {
+	return func(c *comparer) {
+		c.identities = append(c.identities, arrayIdentity{parsePathPattern(pattern, true), identity})
+	}
+}
+
+type arrayIdentity struct {
+	pattern  pathPattern
+	identity IdentityFunc
+}
+
+// arrayIdentity returns the identity function for the array being compared.
+func (c *comparer) arrayIdentity() IdentityFunc {
+	for _, ai := range c.identities {
+		if ai.pattern.match(c.path) {
+			return ai.identity
+		}
+	}
+	return nil
+}
+
+func (c *comparer) compareArraysByIdentity(left, right []any, leftIndices, rightIndices []int, identity IdentityFunc) []Delta {
+	// pair up elements, first come first served
+	unmatched := make(map[any][]int)
+	for _, j := range rightIndices {
+		if id, ok := identityKey(identity, right[j]); ok {
+			unmatched[id] = append(unmatched[id], j)
+		}
+	}
+	pairs := make([]int, len(left)) // left index to right index, or -1
+	paired := make([]bool, len(right))
+	for _, i := range leftIndices {
+		pairs[i] = -1
+		if id, ok := identityKey(identity, left[i]); ok && len(unmatched[id]) > 0 {
+			j := unmatched[id][0]
+			unmatched[id] = unmatched[id][1:]
+			pairs[i] = j
+			paired[j] = true
+		}
+	}
+
+	// pairs in the longest common order stay in place, others move
+	kept := make([]bool, len(left))
+	for _, pair := range lcsIndexPairs(leftIndices, rightIndices, func(i, j int) bool { return pairs[i] == j }) {
+		kept[leftIndices[pair.Left]] = true
+	}
+
+	deltas := make([]Delta, 0)
+	for _, i := range leftIndices {
+		j := pairs[i]
+		if j < 0 {
+			deltas = append(deltas, NewDeleted(Index(i), left[i]))
+			continue
+		}
+		if !kept[i] && c.noMoves {
+			deltas = append(deltas, NewDeleted(Index(i), left[i]))
+			paired[j] = false
+			continue
+		}
+		if !kept[i] {
+			deltas = append(deltas, NewMoved(Index(i), Index(j), left[i]))
+		}
+		if same, delta := c.compareValues(Index(j), left[i], right[j]); !same {
+			deltas = append(deltas, delta)
+		}
+	}
+	for _, j := range rightIndices {
+		if !paired[j] {
+			deltas = append(deltas, NewAdded(Index(j), right[j]))
+		}
+	}
+	return deltas
+}
+
+// identityKey returns the identity of element, normalizing numbers so that
+// e.g. 1 and 1.0 identify the same element.
+func identityKey(identity IdentityFunc, element any) (any, bool) {
+	id, ok := identity(element)
+	if !ok || id == nil || !reflect.ValueOf(id).Comparable() {
+		return nil, false
+	}
+	if f, isNumber := toFloat(id); isNumber {
+		return f, true
+	}
+	return id, true
+}
@@ -0,0 +1,89 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Result holds both parsed documents and the Diff between them.
+type Result struct {
+	Left  any
+	Right any
+	Diff  Diff
+}
+
+// Format renders the diff against the left document, see Diff.Format.
+func (r *Result) Format(opts ...FormatOption) string {
+	return r.Diff.Format(r.Left, opts...)
+}
+
+// CompareJSON parses two JSON documents and compares them, see Compare.
+// Numbers are parsed as json.Number, so large integers keep their precision.
+// Malformed input is reported as a *ParseError.
+func CompareJSON(left, right []byte, opts ...CompareOption) (*Result, error) {
+	return NewComparer(opts...).CompareJSON(left, right)
+}
+
+// CompareJSONReaders is like CompareJSON, but reads the documents from
+// the given readers.
+func CompareJSONReaders(left, right io.Reader, opts ...CompareOption) (*Result, error) {
+	return NewComparer(opts...).CompareJSONReaders(left, right)
+}
+
+// ParseError reports a malformed JSON document.
+type ParseError struct {
+	Side   string // "left" or "right"
+	Offset int64  // byte offset of the error
+	Line   int    // 1-based line number
+	Column int    // 1-based column number, in bytes
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("jsondiff: %s document: line %d, column %d: %v", e.Side, e.Line, e.Column, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// parseJSON decodes a single JSON document, keeping numbers as json.Number
+// so that large integers aren't rounded.
+func parseJSON(side string, data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	err := dec.Decode(&v)
+	end := dec.InputOffset()
+	if err == nil {
+		// anything but whitespace after the document is an error
+		var extra any
+		if err = dec.Decode(&extra); err == io.EOF {
+			return v, nil
+		} else if err == nil {
+			err = errors.New("invalid data after top-level value")
+		}
+	}
+
+	offset := end + int64(len(data[end:])-len(bytes.TrimLeft(data[end:], " \t\r\n")))
+	var se *json.SyntaxError
+	switch {
+	case errors.As(err, &se):
+		offset = se.Offset - 1 // Offset points just past the offending byte
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		err = io.ErrUnexpectedEOF
+		offset = int64(len(data))
+	}
+	offset = min(max(offset, 0), int64(len(data)))
+	line, column := lineColumn(data[:offset])
+	return nil, &ParseError{Side: side, Offset: offset, Line: line, Column: column, Err: err}
+}
+
+func lineColumn(prefix []byte) (line, column int) {
+	line = 1 + bytes.Count(prefix, []byte{'\n'})
+	column = 1 + len(prefix) - (bytes.LastIndexByte(prefix, '\n') + 1)
+	return
+}
@@ -0,0 +1,58 @@
+package jsondiff
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompareJSON(t *testing.T) {
+	res, err := CompareJSONReaders(strings.NewReader(`{"foo": 10, "bar": 20}`), strings.NewReader(`{"foo": 10, "bar": 42}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ` {
+-  "bar": 20,
++  "bar": 42,
+   "foo": 10
+ }`
+	if actual := res.Format(); actual != expected {
+		t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, expected)
+	}
+
+	// integers beyond float64 precision stay distinct
+	res, err = CompareJSON([]byte(`{"id": 9007199254740993}`), []byte(`{"id": 9007199254740992}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = ` {
+-  "id": 9007199254740993
++  "id": 9007199254740992
+ }`
+	if actual := res.Format(); actual != expected {
+		t.Errorf("** DIFF:\n%s\n\nEXPECTED:\n%s", actual, expected)
+	}
+}
+
+func TestCompareJSONParseError(t *testing.T) {
+	tests := []struct {
+		left, right  string
+		side         string
+		line, column int
+	}{
+		{`{"a" 1}`, `{}`, "left", 1, 6},
+		{`{}`, "{\n  \"a\": 1,\n}", "right", 3, 1},
+		{`{}`, `{"a": 1} x`, "right", 1, 10},
+	}
+	for _, tt := range tests {
+		_, err := CompareJSON([]byte(tt.left), []byte(tt.right))
+		var pe *ParseError
+		if !errors.As(err, &pe) {
+			t.Errorf("CompareJSON(%q, %q) error = %v, expected *ParseError", tt.left, tt.right, err)
+			continue
+		}
+		if pe.Side != tt.side || pe.Line != tt.line || pe.Column != tt.column {
+			t.Errorf("CompareJSON(%q, %q) error at %s %d:%d, expected %s %d:%d", tt.left, tt.right, pe.Side, pe.Line, pe.Column, tt.side, tt.line, tt.column)
+		}
+	}
+}
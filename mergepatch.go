@@ -0,0 +1,133 @@
+package jsondiff
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrNotRepresentable is reported by ToMergePatch when the diff cannot be
+// expressed faithfully as a merge patch.
+var ErrNotRepresentable = errors.New("jsondiff: diff is not representable as a merge patch")
+
+// ToMergePatch converts the diff into an RFC 7386 JSON Merge Patch document.
+// Merge patches replace arrays as a whole, so the left document is needed to
+// reconstruct the changed arrays. Arrays compared with UnorderedArrays are
+// reconstructed up to element order.
+//
+// Merge patches use null to delete members, so explicit nulls on the right
+// side cannot be expressed. In that case the best-effort patch is returned
+// along with an error wrapping ErrNotRepresentable that lists the affected
+// paths.
+func (diff Diff) ToMergePatch(left any) (any, error) {
+	g := mergePatchGenerator{}
+	var patch any
+	var err error
+	if d, ok := rootModified(diff); ok {
+		patch = g.replacement("", d.OldValue, d.NewValue)
+	} else if object, ok := left.(map[string]any); ok {
+		patch, err = g.object("", object, diff)
+	} else if array, ok := left.([]any); ok {
+		patch, err = applier{}.array("", array, diff)
+	} else {
+		patch = left
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(g.lossy) > 0 {
+		return patch, fmt.Errorf("%w: explicit null at %s", ErrNotRepresentable, strings.Join(g.lossy, ", "))
+	}
+	return patch, nil
+}
+
+type mergePatchGenerator struct {
+	lossy []string // paths that cannot be represented
+}
+
+func (g *mergePatchGenerator) object(path string, object map[string]any, deltas []Delta) (map[string]any, error) {
+	patch := make(map[string]any, len(deltas))
+	for _, delta := range deltas {
+		name := positionOf(delta).String()
+		childPath := path + "/" + escapePointer(name)
+		switch d := delta.(type) {
+		case *Added:
+			g.checkNulls(childPath, d.Value, true)
+			patch[name] = d.Value
+		case *Deleted:
+			patch[name] = nil
+		case *Modified:
+			patch[name] = g.replacement(childPath, d.OldValue, d.NewValue)
+		case *Object:
+			child, ok := object[name].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("jsondiff: %s: expected an object, got %T", childPath, object[name])
+			}
+			childPatch, err := g.object(childPath, child, d.Deltas)
+			if err != nil {
+				return nil, err
+			}
+			patch[name] = childPatch
+		case *Array:
+			child, ok := object[name].([]any)
+			if !ok {
+				return nil, fmt.Errorf("jsondiff: %s: expected an array, got %T", childPath, object[name])
+			}
+			array, err := applier{}.array(childPath, child, d.Deltas)
+			if err != nil {
+				return nil, err
+			}
+			patch[name] = array
+		default:
+			return nil, fmt.Errorf("jsondiff: %s: unexpected %T", childPath, delta)
+		}
+	}
+	return patch, nil
+}
+
+// replacement returns a patch that replaces oldValue with newValue.
+func (g *mergePatchGenerator) replacement(path string, oldValue, newValue any) any {
+	newObject, ok := newValue.(map[string]any)
+	if !ok {
+		// a null patch at the root replaces the document with null
+		g.checkNulls(path, newValue, path != "")
+		return newValue
+	}
+	oldObject, ok := oldValue.(map[string]any)
+	if !ok {
+		g.checkNulls(path, newValue, true)
+		return newValue
+	}
+
+	// objects are merged rather than replaced, so delete stale members
+	patch := make(map[string]any, len(newObject))
+	for name := range oldObject {
+		if _, ok := newObject[name]; !ok {
+			patch[name] = nil
+		}
+	}
+	for name, value := range newObject {
+		if oldValue, ok := oldObject[name]; ok && reflect.DeepEqual(oldValue, value) {
+			continue
+		}
+		patch[name] = g.replacement(path+"/"+escapePointer(name), oldObject[name], value)
+	}
+	return patch
+}
+
+// checkNulls records nulls in value that a merge patch would treat as
+// deletions, ignoring nulls inside arrays.
+func (g *mergePatchGenerator) checkNulls(path string, value any, checkSelf bool) {
+	switch v := value.(type) {
+	case nil:
+		if checkSelf {
+			g.lossy = append(g.lossy, path)
+		}
+	case map[string]any:
+		for _, name := range sortedKeys(v) {
+			g.checkNulls(path+"/"+escapePointer(name), v[name], true)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package jsondiff
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+// printMultiline prints a modified string value as a diff of its lines. It
+// returns false if either value isn't a string with several lines, leaving
+// the printing to the caller.
+func (f *asciiFormatter) printMultiline(name string, oldValue, newValue any) bool {
+	if !f.config.MultilineStrings {
+		return false
+	}
+	oldString, ok := oldValue.(string)
+	if !ok || !strings.Contains(oldString, "\n") {
+		return false
+	}
+	newString, ok := newValue.(string)
+	if !ok || !strings.Contains(newString, "\n") {
+		return false
+	}
+	if strings.HasSuffix(oldString, "\n") && strings.HasSuffix(newString, "\n") {
+		// the closing delimiter stands for the common final newline
+		oldString, newString = oldString[:len(oldString)-1], newString[:len(newString)-1]
+	}
+	oldLines, newLines := strings.Split(oldString, "\n"), strings.Split(newString, "\n")
+	if len(oldLines)*len(newLines) > maxHighlightCells {
+		return false
+	}
+	pairs := lcsIndexPairs(oldLines, newLines, func(a, b string) bool { return a == b })
+
+	f.modified = false
+	f.newLine(AsciiSame)
+	f.printKey(name)
+	f.print(`"""`)
+	f.closeLine()
+	f.push(name, 0, false)
+	i, j := 0, 0
+	for k := 0; k <= len(pairs); k++ {
+		left, right := len(oldLines), len(newLines)
+		if k < len(pairs) {
+			left, right = pairs[k].Left, pairs[k].Right
+		}
+		f.modified = true
+		for ; i < left; i++ {
+			f.printTextLine(oldLines[i], AsciiDeleted)
+		}
+		for ; j < right; j++ {
+			f.printTextLine(newLines[j], AsciiAdded)
+		}
+		f.modified = false
+		if k < len(pairs) {
+			f.printTextLine(oldLines[i], AsciiSame)
+			i, j = i+1, j+1
+		}
+	}
+	f.pop()
+	f.newLine(AsciiSame)
+	f.print(`"""`)
+	f.printComma()
+	f.closeLine()
+	f.modified = true
+	return true
+}
+
+// printTextLine prints a line of a multi-line string. Quotes and backslashes
+// are kept as is for readability, control characters are escaped.
+func (f *asciiFormatter) printTextLine(text string, marker string) {
+	f.newLine(marker)
+	f.line.raw = true
+	writeTextLine(f.line.buffer, text, f.config.UnescapedUnicode)
+	f.closeLine()
+}
+
+func writeTextLine(buf *bytes.Buffer, s string, unescaped bool) {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch {
+		case r == '\t' || r >= 0x20 && r < 0x7f:
+			buf.WriteRune(r)
+		case r == utf8.RuneError && size == 1:
+			buf.WriteString(`\ufffd`) // invalid UTF-8
+		case r < 0x20 || r == 0x7f || !unescaped || r == '\u2028' || r == '\u2029':
+			writeUnicodeEscape(buf, r)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
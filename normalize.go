@@ -0,0 +1,435 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CompareValues compares two Go values of any type, such as structs, after
+// converting them to the JSON model the way encoding/json would: json struct
+// tags and omitempty are honored, embedded structs promote their fields,
+// and json.Marshaler and encoding.TextMarshaler implementations are used.
+// The converted values are returned in the Result for formatting.
+func CompareValues(left, right any, opts ...CompareOption) (*Result, error) {
+	return NewComparer(opts...).CompareValues(left, right)
+}
+
+// CompareValues compares two Go values of any type, see the CompareValues
+// function.
+func (cmp *Comparer) CompareValues(left, right any) (*Result, error) {
+	l, err := normalize(left)
+	if err != nil {
+		return nil, fmt.Errorf("jsondiff: left value: %w", err)
+	}
+	r, err := normalize(right)
+	if err != nil {
+		return nil, fmt.Errorf("jsondiff: right value: %w", err)
+	}
+	return &Result{Left: l, Right: r, Diff: cmp.Compare(l, r)}, nil
+}
+
+// normalize converts a Go value into nil, bool, string, a number,
+// map[string]any or []any.
+func normalize(value any) (any, error) {
+	n := normalizer{visiting: make(map[visit]bool)}
+	return n.value(reflect.ValueOf(value))
+}
+
+type normalizer struct {
+	visiting map[visit]bool // pointers, maps and slices being converted, to detect cycles
+}
+
+// visit identifies a pointer, map or slice; slices also need their length,
+// since a shorter slice of the same array is not a cycle.
+type visit struct {
+	kind reflect.Kind
+	ptr  uintptr
+	len  int
+}
+
+// enter marks v as being converted, and returns false if it already is.
+func (n *normalizer) enter(v reflect.Value) (visit, bool) {
+	key := visit{kind: v.Kind(), ptr: v.Pointer()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+	if n.visiting[key] {
+		return key, false
+	}
+	n.visiting[key] = true
+	return key, true
+}
+
+var (
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (n *normalizer) value(v reflect.Value) (any, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(v.Type()).Implements(marshalerType) {
+		v = v.Addr()
+	}
+	if v.Type().Implements(marshalerType) {
+		if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+			return nil, nil
+		}
+		return marshalValue(v.Interface().(json.Marshaler))
+	}
+	if v.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(v.Type()).Implements(textMarshalerType) {
+		v = v.Addr()
+	}
+	if v.Type().Implements(textMarshalerType) {
+		if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+			return nil, nil
+		}
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.Float32:
+		return float32(v.Float()), nil
+	case reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		if v.Type() == reflect.TypeOf(json.Number("")) {
+			return json.Number(v.String()), nil
+		}
+		return v.String(), nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return n.value(v.Elem())
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		key, ok := n.enter(v)
+		if !ok {
+			return nil, fmt.Errorf("cycle through %s", v.Type())
+		}
+		defer delete(n.visiting, key)
+		return n.value(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 && !v.Type().Elem().Implements(marshalerType) && !v.Type().Elem().Implements(textMarshalerType) {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+		}
+		key, ok := n.enter(v)
+		if !ok {
+			return nil, fmt.Errorf("cycle through %s", v.Type())
+		}
+		defer delete(n.visiting, key)
+		return n.array(v)
+	case reflect.Array:
+		return n.array(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		key, ok := n.enter(v)
+		if !ok {
+			return nil, fmt.Errorf("cycle through %s", v.Type())
+		}
+		defer delete(n.visiting, key)
+		return n.object(v)
+	case reflect.Struct:
+		return n.structValue(v)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", v.Type())
+	}
+}
+
+func marshalValue(m json.Marshaler) (any, error) {
+	data, err := m.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid output of %T.MarshalJSON: %w", m, err)
+	}
+	return v, nil
+}
+
+func (n *normalizer) array(v reflect.Value) (any, error) {
+	a := make([]any, v.Len())
+	for i := range a {
+		e, err := n.value(v.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		a[i] = e
+	}
+	return a, nil
+}
+
+func (n *normalizer) object(v reflect.Value) (any, error) {
+	m := make(map[string]any, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := mapKey(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		e, err := n.value(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		m[key] = e
+	}
+	return m, nil
+}
+
+// mapKey converts a map key the way encoding/json does.
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	default:
+		return "", fmt.Errorf("unsupported map key type %s", k.Type())
+	}
+}
+
+func (n *normalizer) structValue(v reflect.Value) (any, error) {
+	m := make(map[string]any)
+	for _, f := range structFields(v.Type()) {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		e, err := n.value(fv)
+		if err != nil {
+			return nil, err
+		}
+		if f.quoted {
+			e = quoteScalar(e)
+		}
+		m[f.name] = e
+	}
+	return m, nil
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but reports false
+// instead of panicking on nil embedded pointers.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// quoteScalar implements the ",string" tag option.
+func quoteScalar(v any) any {
+	switch v := v.(type) {
+	case bool, int64, uint64, float32, float64, json.Number:
+		return fmt.Sprint(v)
+	case string:
+		return strconv.Quote(v)
+	default:
+		return v
+	}
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+type structField struct {
+	name      string
+	index     []int
+	tagged    bool
+	omitEmpty bool
+	quoted    bool
+}
+
+// structFields lists the fields encoding/json would encode for t, with
+// fields of embedded structs promoted unless a shallower or tagged field has
+// the same name.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	visited := map[reflect.Type]bool{}
+	current := []structField{{index: nil}}
+	types := []reflect.Type{t}
+	for len(types) > 0 {
+		var next []structField
+		var nextTypes []reflect.Type
+		byName := map[string][]structField{}
+		var names []string
+		for k, st := range types {
+			if visited[st] {
+				continue
+			}
+			visited[st] = true
+			for i := 0; i < st.NumField(); i++ {
+				sf := st.Field(i)
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, options, _ := strings.Cut(tag, ",")
+				index := append(append([]int(nil), current[k].index...), i)
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+					if !sf.IsExported() && sf.Type.Kind() == reflect.Pointer {
+						continue // cannot be set, encoding/json ignores it too
+					}
+					next = append(next, structField{index: index})
+					nextTypes = append(nextTypes, ft)
+					continue
+				}
+				if !sf.IsExported() {
+					continue
+				}
+				f := structField{
+					name:      name,
+					index:     index,
+					tagged:    name != "",
+					omitEmpty: hasOption(options, "omitempty"),
+					quoted:    hasOption(options, "string") && isQuotable(sf.Type),
+				}
+				if f.name == "" {
+					f.name = sf.Name
+				}
+				if len(byName[f.name]) == 0 {
+					names = append(names, f.name)
+				}
+				byName[f.name] = append(byName[f.name], f)
+			}
+		}
+		for _, name := range names {
+			if containsField(fields, name) {
+				continue // shadowed by a shallower field
+			}
+			candidates := byName[name]
+			var tagged []structField
+			for _, f := range candidates {
+				if f.tagged {
+					tagged = append(tagged, f)
+				}
+			}
+			switch {
+			case len(candidates) == 1:
+				fields = append(fields, candidates[0])
+			case len(tagged) == 1:
+				fields = append(fields, tagged[0])
+			default:
+				fields = append(fields, structField{name: name}) // ambiguous, omitted
+			}
+		}
+		current, types = next, nextTypes
+	}
+
+	result := fields[:0]
+	for _, f := range fields {
+		if f.index != nil {
+			result = append(result, f)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return lessIndex(result[i].index, result[j].index) })
+	return result
+}
+
+func hasOption(options, option string) bool {
+	for options != "" {
+		var o string
+		o, options, _ = strings.Cut(options, ",")
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+func isQuotable(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+func containsField(fields []structField, name string) bool {
+	for _, f := range fields {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func lessIndex(a, b []int) bool {
+	for i := range a {
+		if i >= len(b) {
+			return false
+		}
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
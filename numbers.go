@@ -0,0 +1,90 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// NumericTolerance makes numbers compare as equal when they differ by at most
+// absolute, or by at most relative times the larger magnitude of the two.
+// E.g. NumericTolerance(0, 1e-9) treats 0.1+0.2 and 0.3 as equal.
+func NumericTolerance(absolute, relative float64) CompareOption {
+	return func(c *comparer) {
+		c.absTolerance = absolute
+		c.relTolerance = relative
+	}
+}
+
+// numbersEqual compares two values if both are numbers of any Go numeric
+// kind or json.Number. Numbers of different types are compared by their
+// mathematical value.
+func (c *comparer) numbersEqual(left, right any) (numeric, equal bool) {
+	l, ok := toFloat(left)
+	if !ok {
+		return false, false
+	}
+	r, ok := toFloat(right)
+	if !ok {
+		return false, false
+	}
+
+	if c.absTolerance > 0 || c.relTolerance > 0 {
+		diff := math.Abs(l - r)
+		return true, diff <= c.absTolerance || diff <= c.relTolerance*max(math.Abs(l), math.Abs(r))
+	}
+
+	if _, isJSON := left.(json.Number); !isJSON && reflect.TypeOf(left) == reflect.TypeOf(right) {
+		return true, left == right
+	}
+	lr, lok := toRat(left)
+	rr, rok := toRat(right)
+	if !lok || !rok {
+		return true, l == r // NaN or infinity
+	}
+	return true, lr.Cmp(rr) == 0
+}
+
+// toFloat converts a Go numeric value or json.Number to float64.
+func toFloat(v any) (float64, bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil || errors.Is(err, strconv.ErrRange)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// toRat converts a Go numeric value or json.Number to an exact rational.
+func toRat(v any) (*big.Rat, bool) {
+	if n, ok := v.(json.Number); ok {
+		return new(big.Rat).SetString(string(n))
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(rv.Uint())), true
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(f), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,267 @@
+package jsondiff
+
+import (
+	"reflect"
+	"strings"
+)
+
+// CompareOption can be passed to Compare, CompareObjects and CompareJSON to
+// adjust how values are compared.
+type CompareOption func(*comparer)
+
+// IgnorePath excludes the value at the given JSON Pointer, e.g. "/meta/etag",
+// from comparison. Within arrays, index tokens match elements at that index
+// in either array, and such elements are left out before the arrays are
+// aligned.
+func IgnorePath(pointer string) CompareOption {
+	return func(c *comparer) {
+		c.ignoredPaths = append(c.ignoredPaths, parsePathPattern(pointer, false))
+	}
+}
+
+// IgnorePattern is like IgnorePath, but the pattern may contain wildcard
+// tokens: "*" matches any single object member or array element, and "**"
+// matches any number of nesting levels, e.g. "/items/*/updatedAt" or
+// "/**/etag".
+func IgnorePattern(pattern string) CompareOption {
+	return func(c *comparer) {
+		c.ignoredPaths = append(c.ignoredPaths, parsePathPattern(pattern, true))
+	}
+}
+
+// IgnoreKey excludes object members with the given name anywhere in the tree.
+func IgnoreKey(name string) CompareOption {
+	return func(c *comparer) {
+		if c.ignoredKeys == nil {
+			c.ignoredKeys = make(map[string]bool)
+		}
+		c.ignoredKeys[name] = true
+	}
+}
+
+// NullEqualsMissing treats object members whose value is null as equal to
+// absent ones, so that {"a": null} and {} have no differences.
+func NullEqualsMissing() CompareOption {
+	return func(c *comparer) {
+		c.nullMissing = true
+	}
+}
+
+// EmptyEqualsMissing treats object members whose value is an empty array or
+// object as equal to absent ones.
+func EmptyEqualsMissing() CompareOption {
+	return func(c *comparer) {
+		c.emptyMissing = true
+	}
+}
+
+// missingLike reports whether an object member with the given value is
+// equivalent to an absent one.
+func (c *comparer) missingLike(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return c.nullMissing
+	case map[string]any:
+		return c.emptyMissing && len(v) == 0
+	case []any:
+		return c.emptyMissing && len(v) == 0
+	default:
+		return false
+	}
+}
+
+type comparer struct {
+	ignoredPaths []pathPattern
+	ignoredKeys  map[string]bool
+	absTolerance float64
+	relTolerance float64
+	identities   []arrayIdentity
+	unordered    []pathPattern
+	noMoves      bool
+	equalFunc    func(left, right any) bool
+	maxDepth     int
+	overrides    []pathOverride
+	comparators  []comparatorHook
+	nullMissing  bool
+	emptyMissing bool
+
+	similarityThreshold float64
+
+	path []Position // position of the values being compared
+}
+
+func newComparer(opts []CompareOption) *comparer {
+	c := &comparer{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *comparer) push(position Position) {
+	if position != nil {
+		c.path = append(c.path, position)
+	}
+}
+
+func (c *comparer) pop(position Position) {
+	if position != nil {
+		c.path = c.path[:len(c.path)-1]
+	}
+}
+
+// ignores reports whether the child at the given position is excluded from
+// comparison.
+func (c *comparer) ignores(position Position) bool {
+	if name, ok := position.(Name); ok && c.ignoredKeys[string(name)] {
+		return true
+	}
+	if len(c.ignoredPaths) == 0 {
+		return false
+	}
+	c.push(position)
+	defer c.pop(position)
+	for _, pattern := range c.ignoredPaths {
+		if pattern.match(c.path) {
+			return true
+		}
+	}
+	return false
+}
+
+// keptIndices returns the indices of the elements of the array being
+// compared that aren't excluded from comparison.
+func (c *comparer) keptIndices(array []any) []int {
+	kept := make([]int, 0, len(array))
+	for i := range array {
+		if !c.ignores(Index(i)) {
+			kept = append(kept, i)
+		}
+	}
+	return kept
+}
+
+// equal reports whether the values at the given position have no
+// differences, without computing deltas.
+func (c *comparer) equal(position Position, left, right any) bool {
+	c.push(position)
+	defer c.pop(position)
+	if o := c.override(); o != nil {
+		return o.equalHere(left, right)
+	}
+	return c.equalHere(left, right)
+}
+
+// equalHere is like equal for the values at the current path.
+func (c *comparer) equalHere(left, right any) bool {
+	if fn := c.comparator(left, right); fn != nil {
+		equal, _ := fn(left, right)
+		return equal
+	}
+	switch l := left.(type) {
+	case map[string]any:
+		r, ok := right.(map[string]any)
+		if !ok {
+			return false
+		}
+		for name, lv := range l {
+			if c.ignores(Name(name)) {
+				continue
+			}
+			if rv, ok := r[name]; ok && !c.equal(Name(name), lv, rv) || !ok && !c.missingLike(lv) {
+				return false
+			}
+		}
+		for name, rv := range r {
+			if _, ok := l[name]; !ok && !c.ignores(Name(name)) && !c.missingLike(rv) {
+				return false
+			}
+		}
+		return true
+
+	case []any:
+		r, ok := right.([]any)
+		if !ok {
+			return false
+		}
+		leftIndices, rightIndices := c.keptIndices(l), c.keptIndices(r)
+		if len(leftIndices) != len(rightIndices) {
+			return false
+		}
+		if c.isUnordered() {
+			pairs, _ := c.matchUnordered(l, r, leftIndices, rightIndices)
+			for _, i := range leftIndices {
+				if pairs[i] < 0 {
+					return false
+				}
+			}
+			return true
+		}
+		for k, i := range leftIndices {
+			j := rightIndices[k]
+			if !c.equal(Index(j), l[i], r[j]) {
+				return false
+			}
+		}
+		return true
+
+	default:
+		if numeric, equal := c.numbersEqual(left, right); numeric {
+			return equal
+		}
+		if c.equalFunc != nil {
+			return !isContainer(right) && c.equalFunc(left, right)
+		}
+		return reflect.DeepEqual(left, right)
+	}
+}
+
+// pathPattern is a parsed JSON Pointer, possibly with wildcard tokens.
+type pathPattern []patternToken
+
+type patternToken struct {
+	name     string
+	wildcard string // "", "*" or "**"
+}
+
+func parsePathPattern(s string, wildcards bool) pathPattern {
+	if s == "" {
+		return pathPattern{}
+	}
+	tokens := strings.Split(strings.TrimPrefix(s, "/"), "/")
+	pattern := make(pathPattern, len(tokens))
+	for i, token := range tokens {
+		if wildcards && (token == "*" || token == "**") {
+			pattern[i] = patternToken{wildcard: token}
+		} else {
+			pattern[i] = patternToken{name: unescapePointer(token)}
+		}
+	}
+	return pattern
+}
+
+func (p pathPattern) match(path []Position) bool {
+	if len(p) == 0 {
+		return len(path) == 0
+	}
+	switch p[0].wildcard {
+	case "**":
+		for i := 0; i <= len(path); i++ {
+			if p[1:].match(path[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(path) > 0 && p[1:].match(path[1:])
+	default:
+		return len(path) > 0 && path[0].String() == p[0].name && p[1:].match(path[1:])
+	}
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// unescapePointer unescapes a reference token of a JSON Pointer.
+func unescapePointer(token string) string {
+	return pointerUnescaper.Replace(token)
+}
@@ -0,0 +1,181 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JSONPatch is an RFC 6902 JSON Patch document.
+type JSONPatch []Operation
+
+// Operation is a single JSON Patch operation. Value is only meaningful for
+// "add" and "replace", From only for "move".
+type Operation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// MarshalJSON emits "value" for add and replace operations even when it is
+// null, and omits it otherwise.
+func (op Operation) MarshalJSON() ([]byte, error) {
+	switch op.Op {
+	case "add", "replace":
+		return json.Marshal(struct {
+			Op    string `json:"op"`
+			Path  string `json:"path"`
+			Value any    `json:"value"`
+		}{op.Op, op.Path, op.Value})
+	case "move":
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			From string `json:"from"`
+			Path string `json:"path"`
+		}{op.Op, op.From, op.Path})
+	default:
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{op.Op, op.Path})
+	}
+}
+
+// ToJSONPatch converts the diff into JSON Patch operations which, applied
+// in order to the left document, produce the right one. For arrays compared
+// with UnorderedArrays, elements may end up in a different order.
+func (diff Diff) ToJSONPatch() (JSONPatch, error) {
+	if d, ok := rootModified(diff); ok {
+		return JSONPatch{{Op: "replace", Path: "", Value: d.NewValue}}, nil
+	}
+	var g patchGenerator
+	var err error
+	if diff.isArray() {
+		err = g.array("", diff)
+	} else {
+		err = g.object("", diff)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g.ops, nil
+}
+
+type patchGenerator struct {
+	ops JSONPatch
+}
+
+func (g *patchGenerator) add(op, path string, value any) {
+	g.ops = append(g.ops, Operation{Op: op, Path: path, Value: value})
+}
+
+func (g *patchGenerator) object(path string, deltas []Delta) error {
+	for _, delta := range deltas {
+		switch d := delta.(type) {
+		case *Added:
+			g.add("add", path+"/"+escapePointer(d.Position.String()), d.Value)
+		case *Deleted:
+			g.add("remove", path+"/"+escapePointer(d.Position.String()), nil)
+		default:
+			if err := g.value(path, delta); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// value handles deltas that change an element in place.
+func (g *patchGenerator) value(parent string, delta Delta) error {
+	switch d := delta.(type) {
+	case *Modified:
+		g.add("replace", parent+"/"+escapePointer(d.Position.String()), d.NewValue)
+		return nil
+	case *Object:
+		return g.object(parent+"/"+escapePointer(d.Position.String()), d.Deltas)
+	case *Array:
+		return g.array(parent+"/"+escapePointer(d.Position.String()), d.Deltas)
+	default:
+		return fmt.Errorf("jsondiff: unexpected %T at %s", delta, parent)
+	}
+}
+
+func (g *patchGenerator) array(path string, deltas []Delta) error {
+	items, rightLen, err := alignArray(-1, deltas)
+	if err != nil {
+		return fmt.Errorf("jsondiff: %s: %w", path, err)
+	}
+
+	// current holds the left indices of the elements as the patch is
+	// applied, -1 for added elements
+	var current []int
+	for _, item := range items {
+		if item.left >= 0 && item.kind != itemMovedTo {
+			current = append(current, item.left)
+		}
+	}
+
+	for i := len(items) - 1; i >= 0; i-- {
+		if item := items[i]; item.kind == itemDeleted {
+			g.add("remove", fmt.Sprintf("%s/%d", path, item.left), nil)
+			current = removeIndex(current, item.left)
+		}
+	}
+
+	target := make([]arrayItem, rightLen)
+	for _, item := range items {
+		if item.right >= 0 && item.kind != itemMovedFrom {
+			target[item.right] = item
+		}
+	}
+	for j, item := range target {
+		if item.kind == itemAdded {
+			g.add("add", fmt.Sprintf("%s/%d", path, j), item.delta.(*Added).Value)
+			current = insertIndex(current, j, -1)
+			continue
+		}
+		p := indexOf(current, item.left)
+		if p != j {
+			g.ops = append(g.ops, Operation{Op: "move", From: fmt.Sprintf("%s/%d", path, p), Path: fmt.Sprintf("%s/%d", path, j)})
+			current = insertIndex(removeIndex(current, item.left), j, item.left)
+		}
+	}
+
+	for _, item := range target {
+		if item.change != nil {
+			if err := g.value(path, item.change); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func indexOf(s []int, v int) int {
+	for i, e := range s {
+		if e == v {
+			return i
+		}
+	}
+	return -1
+}
+
+func removeIndex(s []int, v int) []int {
+	i := indexOf(s, v)
+	return append(s[:i], s[i+1:]...)
+}
+
+func insertIndex(s []int, i int, v int) []int {
+	s = append(s, 0)
+	copy(s[i+1:], s[i:])
+	s[i] = v
+	return s
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// escapePointer escapes a reference token of a JSON Pointer (RFC 6901).
+func escapePointer(token string) string {
+	return pointerEscaper.Replace(token)
+}
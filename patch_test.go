@@ -0,0 +1,312 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var roundTripTests = []struct {
+	name  string
+	left  string
+	right string
+}{
+	{"objects", `{"a": 1, "b": {"c": [1, 2]}, "d": null}`, `{"a": 2, "b": {"c": [1, 2, 3], "e": true}, "f": null}`},
+	{"escaping", `{"a/b": 1, "c~d": {"~1": 2}}`, `{"a/b": 2, "c~d": {"~1": 3, "/": 4}}`},
+	{"array_append", `[1, 2]`, `[1, 2, 3, 4]`},
+	{"array_delete", `[1, 2, 3, 4, 5]`, `[2, 4]`},
+	{"array_modify", `[1, {"a": 1}, "x"]`, `[1, {"a": 2}, "y"]`},
+	{"array_reverse", `[1, 2, 3, 4]`, `[4, 3, 2, 1]`},
+	{"array_rotate", `["a", "b", "c", "d", "e"]`, `["e", "a", "b", "c", "d"]`},
+	{"array_mixed", `["a", "b", {"c": 1}, "d", "e", [1]]`, `["x", "e", "b", {"c": 2}, "a", [1, 2], "y"]`},
+	{"nested_arrays", `{"m": [[1, 2], [3, 4]]}`, `{"m": [[3, 4, 5], [2, 1]]}`},
+	{"root_scalar", `"foo"`, `[1]`},
+	{"same", `{"a": [1]}`, `{"a": [1]}`},
+}
+
+func TestToJSONPatchRoundTrip(t *testing.T) {
+	for _, tt := range roundTripTests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, right := parseTestJSON(tt.left), parseTestJSON(tt.right)
+			patch, err := Compare(left, right).ToJSONPatch()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			raw, err := json.Marshal(patch)
+			ensure(err)
+			var ops []map[string]any
+			ensure(json.Unmarshal(raw, &ops))
+
+			actual, err := applyJSONPatch(parseTestJSON(tt.left), ops)
+			if err != nil {
+				t.Fatalf("%v\npatch: %s", err, raw)
+			}
+			if !reflect.DeepEqual(actual, right) {
+				t.Errorf("** PATCHED:\n%v\n\nEXPECTED:\n%v\n\npatch: %s", actual, right, raw)
+			}
+		})
+	}
+}
+
+func TestToJSONPatch(t *testing.T) {
+	left, right := parseTestJSON(`{"a/b": [1, 2, 3], "c": null}`), parseTestJSON(`{"a/b": [3, 1], "c": 1}`)
+	patch, err := Compare(left, right).ToJSONPatch()
+	ensure(err)
+	raw, err := json.Marshal(patch)
+	ensure(err)
+	expected := `[{"op":"remove","path":"/a~1b/1"},{"op":"move","from":"/a~1b/1","path":"/a~1b/0"},{"op":"replace","path":"/c","value":1}]`
+	if string(raw) != expected {
+		t.Errorf("** PATCH:\n%s\n\nEXPECTED:\n%s", raw, expected)
+	}
+}
+
+func TestToMergePatchRoundTrip(t *testing.T) {
+	for _, tt := range roundTripTests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, right := parseTestJSON(tt.left), parseTestJSON(tt.right)
+			patch, err := Compare(left, right).ToMergePatch(left)
+			if err != nil {
+				if errors.Is(err, ErrNotRepresentable) {
+					t.Skip(err)
+				}
+				t.Fatal(err)
+			}
+			actual := applyMergePatch(parseTestJSON(tt.left), patch)
+			if !reflect.DeepEqual(actual, right) {
+				t.Errorf("** PATCHED:\n%v\n\nEXPECTED:\n%v\n\npatch: %v", actual, right, patch)
+			}
+		})
+	}
+}
+
+func TestToMergePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		left     string
+		right    string
+		expected string
+		lossy    bool
+	}{
+		{"members", `{"a": 1, "b": 2, "c": {"d": 3, "e": 4}}`, `{"a": 1, "c": {"d": 5, "e": 4}, "f": "g"}`, `{"b":null,"c":{"d":5},"f":"g"}`, false},
+		{"array", `{"a": [1, 2, 3]}`, `{"a": [1, null, 3, 4]}`, `{"a":[1,null,3,4]}`, false},
+		{"type_change", `{"a": [1]}`, `{"a": {"b": 1}}`, `{"a":{"b":1}}`, false},
+		{"explicit_null", `{"a": 1}`, `{"a": null, "b": {"c": null}}`, `{"a":null,"b":{"c":null}}`, true},
+		{"root", `[1]`, `{"a": 1}`, `{"a":1}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left := parseTestJSON(tt.left)
+			patch, err := Compare(left, parseTestJSON(tt.right)).ToMergePatch(left)
+			if tt.lossy != errors.Is(err, ErrNotRepresentable) {
+				t.Errorf("ToMergePatch error = %v, expected lossy %v", err, tt.lossy)
+			}
+			raw, err := json.Marshal(patch)
+			ensure(err)
+			if string(raw) != tt.expected {
+				t.Errorf("** PATCH:\n%s\n\nEXPECTED:\n%s", raw, tt.expected)
+			}
+		})
+	}
+}
+
+func TestApplyRevert(t *testing.T) {
+	for _, tt := range roundTripTests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, right := parseTestJSON(tt.left), parseTestJSON(tt.right)
+			diff := Compare(left, right)
+
+			actual, err := diff.Apply(left)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(actual, right) {
+				t.Errorf("** APPLIED:\n%v\n\nEXPECTED:\n%v", actual, right)
+			}
+
+			actual, err = diff.Revert(right)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(actual, left) {
+				t.Errorf("** REVERTED:\n%v\n\nEXPECTED:\n%v", actual, left)
+			}
+			if !reflect.DeepEqual(left, parseTestJSON(tt.left)) || !reflect.DeepEqual(right, parseTestJSON(tt.right)) {
+				t.Errorf("inputs modified")
+			}
+		})
+	}
+}
+
+func TestApplyMismatch(t *testing.T) {
+	diff := Compare(parseTestJSON(`{"a": {"b": [1, 2, 3]}}`), parseTestJSON(`{"a": {"b": [1, 3]}}`))
+	_, err := diff.Apply(parseTestJSON(`{"a": {"b": [1, 5, 3]}}`))
+	var me *MismatchError
+	if !errors.As(err, &me) {
+		t.Fatalf("Apply error = %v, expected *MismatchError", err)
+	}
+	if me.Path != "/a/b/1" {
+		t.Errorf("Apply error path = %q, expected /a/b/1", me.Path)
+	}
+	if _, ok := me.Delta.(*Deleted); !ok {
+		t.Errorf("Apply error delta = %#v, expected *Deleted", me.Delta)
+	}
+}
+
+func parseTestJSON(s string) any {
+	var v any
+	ensure(json.Unmarshal([]byte(s), &v))
+	return v
+}
+
+// applyJSONPatch is a minimal RFC 6902 implementation supporting the
+// operations produced by ToJSONPatch.
+func applyJSONPatch(doc any, ops []map[string]any) (any, error) {
+	var err error
+	for _, op := range ops {
+		path, _ := op["path"].(string)
+		switch op["op"] {
+		case "add":
+			doc, err = pointerUpdate(doc, path, func(container any, token string) (any, error) {
+				return pointerInsert(container, token, op["value"])
+			})
+		case "remove":
+			doc, err = pointerUpdate(doc, path, pointerRemove)
+		case "replace":
+			if path == "" {
+				doc = op["value"]
+				continue
+			}
+			doc, err = pointerUpdate(doc, path, func(container any, token string) (any, error) {
+				container, err := pointerRemove(container, token)
+				if err != nil {
+					return nil, err
+				}
+				return pointerInsert(container, token, op["value"])
+			})
+		case "move":
+			from, _ := op["from"].(string)
+			var value any
+			doc, err = pointerUpdate(doc, from, func(container any, token string) (any, error) {
+				value, err = pointerGet(container, token)
+				if err != nil {
+					return nil, err
+				}
+				return pointerRemove(container, token)
+			})
+			if err == nil {
+				doc, err = pointerUpdate(doc, path, func(container any, token string) (any, error) {
+					return pointerInsert(container, token, value)
+				})
+			}
+		default:
+			err = fmt.Errorf("unsupported op %v", op["op"])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func pointerUpdate(doc any, path string, fn func(container any, token string) (any, error)) (any, error) {
+	if path == "" {
+		return doc, fmt.Errorf("root replacement via %q", path)
+	}
+	tokens := strings.Split(path, "/")[1:]
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	var walk func(v any, tokens []string) (any, error)
+	walk = func(v any, tokens []string) (any, error) {
+		if len(tokens) == 1 {
+			return fn(v, tokens[0])
+		}
+		child, err := pointerGet(v, tokens[0])
+		if err != nil {
+			return nil, err
+		}
+		child, err = walk(child, tokens[1:])
+		if err != nil {
+			return nil, err
+		}
+		switch c := v.(type) {
+		case map[string]any:
+			c[tokens[0]] = child
+		case []any:
+			i, _ := strconv.Atoi(tokens[0])
+			c[i] = child
+		}
+		return v, nil
+	}
+	return walk(doc, tokens)
+}
+
+func pointerGet(container any, token string) (any, error) {
+	switch c := container.(type) {
+	case map[string]any:
+		if v, ok := c[token]; ok {
+			return v, nil
+		}
+	case []any:
+		if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < len(c) {
+			return c[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no %q in %v", token, container)
+}
+
+func pointerInsert(container any, token string, value any) (any, error) {
+	switch c := container.(type) {
+	case map[string]any:
+		c[token] = value
+		return c, nil
+	case []any:
+		if i, err := strconv.Atoi(token); err == nil && i >= 0 && i <= len(c) {
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot add %q to %v", token, container)
+}
+
+func pointerRemove(container any, token string) (any, error) {
+	if _, err := pointerGet(container, token); err != nil {
+		return nil, err
+	}
+	switch c := container.(type) {
+	case map[string]any:
+		delete(c, token)
+		return c, nil
+	default:
+		i, _ := strconv.Atoi(token)
+		a := c.([]any)
+		return append(a[:i:i], a[i+1:]...), nil
+	}
+}
+
+// applyMergePatch implements RFC 7386.
+func applyMergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any)
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+		} else {
+			t[name] = applyMergePatch(t[name], value)
+		}
+	}
+	return t
+}
@@ -0,0 +1,139 @@
+package jsondiff
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FormatPaths renders the diff as a flat list with one line per change:
+//
+//	~ /obj/num: 19 → 9999
+//	- /null: null
+//	+ /obj/new: "added"
+//	↔ /arr/3 → /arr/1
+//
+// Paths are JSON Pointers unless DottedPaths is given; the root itself is
+// written as "(root)". Within arrays, deleted elements and the source of
+// moves use left indices, everything else right indices.
+func (diff Diff) FormatPaths(opts ...FormatOption) string {
+	var buf strings.Builder
+	diff.RenderPathsTo(&buf, opts...)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// RenderPathsTo is like FormatPaths, but streams newline-terminated lines to
+// w, returning the first write error.
+func (diff Diff) RenderPathsTo(w io.Writer, opts ...FormatOption) error {
+	var config asciiFormatterConfig
+	for _, opt := range opts {
+		opt.applyFormat(&config)
+	}
+	bw := bufio.NewWriter(w)
+	f := pathFormatter{config: config, out: bw}
+	f.deltas("", diff)
+	if f.err == nil {
+		f.err = bw.Flush()
+	}
+	return f.err
+}
+
+type pathFormatter struct {
+	config asciiFormatterConfig
+	out    *bufio.Writer
+	err    error // first write error
+	line   bytes.Buffer
+}
+
+func (f *pathFormatter) deltas(parent string, deltas []Delta) {
+	for _, delta := range deltas {
+		switch d := delta.(type) {
+		case *Object:
+			f.deltas(f.join(parent, d.Position), d.Deltas)
+		case *Array:
+			f.deltas(f.join(parent, d.Position), d.Deltas)
+		case *Modified:
+			f.newLine("~", f.join(parent, d.Position))
+			f.line.WriteString(": ")
+			writeJSONScalar(&f.line, d.OldValue, f.config.UnescapedUnicode)
+			f.line.WriteString(" → ")
+			writeJSONScalar(&f.line, d.NewValue, f.config.UnescapedUnicode)
+			f.closeLine()
+		case *Deleted:
+			f.newLine("-", f.join(parent, d.Position))
+			f.line.WriteString(": ")
+			writeJSONScalar(&f.line, d.Value, f.config.UnescapedUnicode)
+			f.closeLine()
+		case *Added:
+			f.newLine("+", f.join(parent, d.Position))
+			f.line.WriteString(": ")
+			writeJSONScalar(&f.line, d.Value, f.config.UnescapedUnicode)
+			f.closeLine()
+		case *Moved:
+			f.newLine("↔", f.join(parent, d.OldPosition))
+			f.line.WriteString(" → ")
+			f.line.WriteString(f.root(f.join(parent, d.NewPosition)))
+			f.closeLine()
+		}
+	}
+}
+
+func (f *pathFormatter) newLine(marker, path string) {
+	f.line.Reset()
+	f.line.WriteString(marker + " " + f.root(path))
+}
+
+func (f *pathFormatter) closeLine() {
+	f.line.WriteByte('\n')
+	if f.err == nil {
+		_, f.err = f.out.Write(f.line.Bytes())
+	}
+}
+
+// join appends a position to a path, leaving it as is for a nil position.
+func (f *pathFormatter) join(parent string, position Position) string {
+	switch p := position.(type) {
+	case nil:
+		return parent
+	case Index:
+		if f.config.DottedPaths {
+			return fmt.Sprintf("%s[%d]", parent, int(p))
+		}
+		return fmt.Sprintf("%s/%d", parent, int(p))
+	default:
+		name := position.String()
+		if !f.config.DottedPaths {
+			return parent + "/" + escapePointer(name)
+		}
+		if isIdentifier(name) {
+			if parent == "" {
+				return name
+			}
+			return parent + "." + name
+		}
+		var buf bytes.Buffer
+		writeJSONString(&buf, name, f.config.UnescapedUnicode)
+		return parent + "[" + buf.String() + "]"
+	}
+}
+
+func (f *pathFormatter) root(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		switch {
+		case r == '_' || r == '$' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,144 @@
+package jsondiff
+
+import (
+	"os"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Width sets the total width of SideBySide output in columns. By default
+// FormatOptions.LineWidth or the COLUMNS environment variable is used,
+// falling back to 120.
+func Width(columns int) FormatOption {
+	return width(columns)
+}
+
+type width int
+
+func (w width) applyFormat(config *asciiFormatterConfig) {
+	config.Width = int(w)
+}
+
+const sideSeparator = " | "
+
+// sideBySide lays out the line stream in two columns. Lines present on one
+// side only are buffered so that runs of deleted and added lines end up
+// next to each other.
+type sideBySide struct {
+	column      int // width of each column
+	left, right []formattedLine
+	row, cell   []byte
+}
+
+func newSideBySide(total int) *sideBySide {
+	if total <= 0 {
+		total, _ = strconv.Atoi(os.Getenv("COLUMNS"))
+	}
+	if total <= 0 {
+		total = 120
+	}
+	return &sideBySide{column: max((total-len(sideSeparator))/2, 8)}
+}
+
+func (s *sideBySide) add(f *asciiFormatter, line formattedLine) {
+	line.text = append([]byte(nil), line.text...)
+	line.spans = append([]textSpan(nil), line.spans...)
+	switch line.side {
+	case sideLeft:
+		s.left = append(s.left, line)
+	case sideRight:
+		s.right = append(s.right, line)
+	default:
+		s.flush(f)
+		s.writeRow(f, &line, &line)
+	}
+}
+
+func (s *sideBySide) finish(f *asciiFormatter) {
+	s.flush(f)
+}
+
+func (s *sideBySide) flush(f *asciiFormatter) {
+	for i := 0; i < len(s.left) || i < len(s.right); i++ {
+		var left, right *formattedLine
+		if i < len(s.left) {
+			left = &s.left[i]
+		}
+		if i < len(s.right) {
+			right = &s.right[i]
+		}
+		s.writeRow(f, left, right)
+	}
+	s.left, s.right = s.left[:0], s.right[:0]
+}
+
+func (s *sideBySide) writeRow(f *asciiFormatter, left, right *formattedLine) {
+	s.row = s.row[:0]
+	s.writeCell(f, left, true)
+	s.row = append(s.row, sideSeparator...)
+	s.writeCell(f, right, false)
+	for len(s.row) > 0 && s.row[len(s.row)-1] == ' ' {
+		s.row = s.row[:len(s.row)-1]
+	}
+	s.row = append(s.row, '\n')
+	f.write(s.row)
+}
+
+// writeCell writes a line truncated to the column width, padding it if pad
+// is set.
+func (s *sideBySide) writeCell(f *asciiFormatter, line *formattedLine, pad bool) {
+	n := 0
+	if line != nil {
+		style := ""
+		if f.config.Coloring {
+			style = f.theme.lineStyle(*line)
+		}
+		colored := style != ""
+		if colored {
+			s.row = append(s.row, "\x1b["+style+"m"...)
+		}
+		s.cell = append(s.cell[:0], f.marker(line.marker)...)
+		for i := 0; i < line.indent; i++ {
+			s.cell = append(s.cell, f.config.Indent...)
+		}
+		open, close := highlightMarkers(line.marker)
+		line.eachSegment(func(text []byte, kind spanKind) {
+			if kind == spanHighlight {
+				s.cell = append(s.cell, open...)
+				s.cell = append(s.cell, text...)
+				s.cell = append(s.cell, close...)
+			} else {
+				s.cell = append(s.cell, text...)
+			}
+		})
+		if line.note != "" {
+			s.cell = append(s.cell, " // "+line.note...)
+		}
+		n = s.appendClipped(s.cell)
+		if colored {
+			for ; pad && n < s.column; n++ {
+				s.row = append(s.row, ' ')
+			}
+			s.row = append(s.row, "\x1b[0m"...)
+		}
+	}
+	for ; pad && n < s.column; n++ {
+		s.row = append(s.row, ' ')
+	}
+}
+
+// appendClipped appends text, ending it with an ellipsis if it doesn't fit
+// the column. It returns the number of columns written.
+func (s *sideBySide) appendClipped(text []byte) int {
+	if utf8.RuneCount(text) <= s.column {
+		s.row = append(s.row, text...)
+		return utf8.RuneCount(text)
+	}
+	for n := 0; n < s.column-1; n++ {
+		r, size := utf8.DecodeRune(text)
+		s.row = utf8.AppendRune(s.row, r)
+		text = text[size:]
+	}
+	s.row = append(s.row, "…"...)
+	return s.column
+}
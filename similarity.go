@@ -4,18 +4,24 @@ import "reflect"
 
 func modifiedSimilarity(oldValue, newValue interface{}) float64 {
 	similarity := 0.3 // at least, they are at the same position
-	if reflect.TypeOf(oldValue) == reflect.TypeOf(newValue) {
+	oldNumber, oldIsNumber := toFloat(oldValue)
+	newNumber, newIsNumber := toFloat(newValue)
+	if oldIsNumber && newIsNumber {
+		similarity += 0.3 // both are numbers
+
+		ratio := oldNumber / newNumber
+		if ratio > 1 {
+			ratio = 1 / ratio
+		}
+		if ratio > 0 {
+			similarity += 0.4 * ratio
+		}
+	} else if reflect.TypeOf(oldValue) == reflect.TypeOf(newValue) {
 		similarity += 0.3 // types are same
 
 		switch oldValue.(type) {
 		case string:
 			similarity += 0.4 * stringSimilarity(oldValue.(string), newValue.(string))
-		case float64:
-			ratio := oldValue.(float64) / newValue.(float64)
-			if ratio > 1 {
-				ratio = 1 / ratio
-			}
-			similarity += 0.4 * ratio
 		}
 	}
 	return similarity
@@ -0,0 +1,112 @@
+package jsondiff
+
+// Theme sets the colors of Colored output. Each field holds SGR parameters,
+// e.g. "30;42" for black on green or "38;2;255;128;0" for a truecolor
+// foreground; empty fields leave the text unstyled. Pass a Theme to Format
+// like any other FormatOption; it implies Colored.
+type Theme struct {
+	Added       string
+	Deleted     string
+	Modified    string // old and new values of modified properties, Added and Deleted if empty
+	Moved       string
+	Unchanged   string
+	Key         string // object keys and array indices
+	Punctuation string // brackets, colons and commas
+	Collapsed   string // lines standing for unchanged lines, see ContextLines
+	Highlight   string // changed characters, see InlineHighlight
+}
+
+// Built-in themes.
+var (
+	// BackgroundTheme is the default, with black text on colored backgrounds.
+	BackgroundTheme = Theme{
+		Added:     "30;42",
+		Deleted:   "30;41",
+		Moved:     "30;43",
+		Highlight: "7",
+	}
+	// ForegroundTheme only colors the text, for terminals with colored or
+	// transparent backgrounds.
+	ForegroundTheme = Theme{
+		Added:       "32",
+		Deleted:     "31",
+		Moved:       "33",
+		Punctuation: "2",
+		Collapsed:   "2;3",
+		Highlight:   "1;4",
+	}
+	// Color256Theme uses muted backgrounds from the 256-color palette.
+	Color256Theme = Theme{
+		Added:       "38;5;22;48;5;194",
+		Deleted:     "38;5;88;48;5;224",
+		Moved:       "38;5;94;48;5;230",
+		Key:         "38;5;25",
+		Punctuation: "38;5;244",
+		Collapsed:   "38;5;244;3",
+		Highlight:   "1;7",
+	}
+	// TruecolorTheme uses 24-bit colors similar to code review tools.
+	TruecolorTheme = Theme{
+		Added:       "38;2;17;99;41;48;2;218;251;225",
+		Deleted:     "38;2;130;7;26;48;2;255;235;233",
+		Moved:       "38;2;9;105;218;48;2;221;244;255",
+		Key:         "38;2;5;80;174",
+		Punctuation: "38;2;110;119;129",
+		Collapsed:   "38;2;110;119;129;3",
+		Highlight:   "1;7",
+	}
+	// HighContrastTheme uses bold text on blue and orange, which stay
+	// distinguishable with red-green color blindness.
+	HighContrastTheme = Theme{
+		Added:     "1;38;5;231;48;5;25",
+		Deleted:   "1;38;5;16;48;5;214",
+		Moved:     "1;38;5;231;48;5;90",
+		Collapsed: "1",
+		Highlight: "4;7",
+	}
+)
+
+func (t Theme) applyFormat(config *asciiFormatterConfig) {
+	config.Coloring = true
+	config.Theme = &t
+}
+
+// themeFromStyles builds the theme for output without a Theme option.
+func themeFromStyles(styles map[string]string) *Theme {
+	t := BackgroundTheme
+	t.Added, t.Deleted, t.Moved = styles[AsciiAdded], styles[AsciiDeleted], styles[AsciiMoved]
+	t.Unchanged = styles[AsciiSame]
+	return &t
+}
+
+func (t *Theme) lineStyle(line formattedLine) string {
+	switch {
+	case line.collapsed:
+		return t.Collapsed
+	case line.modified && t.Modified != "" && line.marker != AsciiSame:
+		return t.Modified
+	}
+	switch line.marker {
+	case AsciiAdded:
+		return t.Added
+	case AsciiDeleted:
+		return t.Deleted
+	case AsciiMoved:
+		return t.Moved
+	default:
+		return t.Unchanged
+	}
+}
+
+func (t *Theme) spanStyle(kind spanKind) string {
+	switch kind {
+	case spanHighlight:
+		return t.Highlight
+	case spanKey:
+		return t.Key
+	case spanPunctuation:
+		return t.Punctuation
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,64 @@
+package jsondiff
+
+// UnorderedArrays compares arrays as multisets: elements present on both
+// sides produce no deltas regardless of their order, and only elements
+// without an equal counterpart are reported as deleted or added. Without
+// arguments it applies to all arrays, otherwise to arrays matching any of
+// the path patterns (see IgnorePattern).
+//
+// Applying such a diff reproduces the right array up to element order.
+func UnorderedArrays(patterns ...string) CompareOption {
+	return func(c *comparer) {
+		if len(patterns) == 0 {
+			c.unordered = append(c.unordered, pathPattern{{wildcard: "**"}})
+		}
+		for _, pattern := range patterns {
+			c.unordered = append(c.unordered, parsePathPattern(pattern, true))
+		}
+	}
+}
+
+// isUnordered reports whether the array being compared is a multiset.
+func (c *comparer) isUnordered() bool {
+	for _, pattern := range c.unordered {
+		if pattern.match(c.path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchUnordered pairs equal elements of left and right at the given
+// indices regardless of their order, returning the right index for each
+// left element, or -1.
+func (c *comparer) matchUnordered(left, right []any, leftIndices, rightIndices []int) (pairs []int, paired []bool) {
+	pairs = make([]int, len(left))
+	paired = make([]bool, len(right))
+	for _, i := range leftIndices {
+		pairs[i] = -1
+		for _, j := range rightIndices {
+			if !paired[j] && c.equal(Index(j), left[i], right[j]) {
+				pairs[i] = j
+				paired[j] = true
+				break
+			}
+		}
+	}
+	return pairs, paired
+}
+
+func (c *comparer) compareArraysUnordered(left, right []any, leftIndices, rightIndices []int) []Delta {
+	pairs, paired := c.matchUnordered(left, right, leftIndices, rightIndices)
+	deltas := make([]Delta, 0)
+	for _, i := range leftIndices {
+		if pairs[i] < 0 {
+			deltas = append(deltas, NewDeleted(Index(i), left[i]))
+		}
+	}
+	for _, j := range rightIndices {
+		if !paired[j] {
+			deltas = append(deltas, NewAdded(Index(j), right[j]))
+		}
+	}
+	return deltas
+}